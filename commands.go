@@ -0,0 +1,9 @@
+package main
+
+// commands are run from the command line in place of the server,
+// e.g. "brahman eval {projectID} {file}"
+// Database and redis connections are available to them
+var commands = map[string]func(args []string) error{
+	"eval": evalCommand,
+	"play": playCommand,
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/brahman/nlu"
+	"github.com/talkative-ai/core/models"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// Labels used in place of a dialog ID in the evaluation report
+const (
+	evalNoMatch   = "(none)"
+	evalAmbiguous = "(ambiguous)"
+)
+
+// evalRow is a single utterance to evaluate
+// Expected is the dialog ID which should be triggered, or empty if none should be
+// Start is the dialog ID the conversation is in beforehand, or empty to match the root dialogs
+type evalRow struct {
+	Utterance string `json:"utterance"`
+	Expected  string `json:"expected"`
+	Start     string `json:"start"`
+}
+
+// evalCommand runs utterances through dialog matching for a published project and reports accuracy
+// Usage: brahman eval [-min-accuracy 0.9] [-locale es-ES] {projectID} {file.csv|file.jsonl}
+// CSV rows are: utterance, expected dialog ID, optional starting dialog ID
+// Only kalidasa is evaluated, so the run fails rather than falling back when it's unavailable
+func evalCommand(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	minAccuracy := flags.Float64("min-accuracy", 0, "fail when accuracy is below this fraction")
+	userLocale := flags.String("locale", "", "locale of the utterances, e.g. es-ES")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: brahman eval [-min-accuracy 0.9] [-locale es-ES] {projectID} {file.csv|file.jsonl}")
+	}
+	nlu.Matcher = nlu.NewKalidasa(os.Getenv("KALIDASA_ADDR"))
+	ctx := locale.With(context.Background(), *userLocale)
+
+	projectID, err := uuid.FromString(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bad project ID: %v", err)
+	}
+	rows, err := readEvalRows(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	// The starting state is shared by every row
+	start := models.AIRequest{
+		State:      models.MutableAIRequestState{},
+		OutputSSML: ssml.NewBuilder(),
+	}
+	start.State.ProjectID = projectID
+	start.State.PubID = intentHandlers.LocalizedPubID(ctx, projectID.String())
+	var setup models.RAResetApp
+	setup.Execute(&start)
+
+	confusion := map[string]map[string]int{}
+	var unmatched []evalRow
+	correct := 0
+	for i, row := range rows {
+		state := start.State
+		if row.Start != "" {
+			startDialog := row.Start
+			state.CurrentDialog = &startDialog
+		}
+
+		explanation, err := intentHandlers.Explain(ctx, row.Utterance, &state)
+		if err != nil {
+			return fmt.Errorf("row %v: %v", i+1, err)
+		}
+
+		// Landing in a catch-all unknown dialog means nothing matched
+		predicted := evalNoMatch
+		if len(explanation.Choices) > 0 {
+			predicted = evalAmbiguous
+		} else if explanation.Winner != "" && !explanation.CatchAll {
+			predicted = intentHandlers.DialogIDFromKey(explanation.Winner)
+		}
+		expected := evalNoMatch
+		if row.Expected != "" {
+			expected = intentHandlers.DialogIDFromKey(row.Expected)
+		}
+
+		if confusion[expected] == nil {
+			confusion[expected] = map[string]int{}
+		}
+		confusion[expected][predicted]++
+		if predicted == expected {
+			correct++
+		}
+		if predicted == evalNoMatch && expected != evalNoMatch {
+			unmatched = append(unmatched, row)
+		}
+	}
+
+	accuracy := 0.0
+	if len(rows) > 0 {
+		accuracy = float64(correct) / float64(len(rows))
+	}
+	printEvalReport(os.Stdout, len(rows), correct, accuracy, confusion, unmatched)
+
+	if accuracy < *minAccuracy {
+		return fmt.Errorf("accuracy %.3f is below the minimum of %.3f", accuracy, *minAccuracy)
+	}
+	return nil
+}
+
+// readEvalRows reads a CSV or JSONL file, chosen by its extension
+func readEvalRows(path string) ([]evalRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows := []evalRow{}
+	if strings.ToLower(filepath.Ext(path)) == ".jsonl" {
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var row evalRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				return nil, fmt.Errorf("%v line %v: %v", path, line, err)
+			}
+			rows = append(rows, row)
+		}
+		return rows, scanner.Err()
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%v line %v: expected an utterance and a dialog", path, i+1)
+		}
+		// Allow a header row
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "utterance") {
+			continue
+		}
+		row := evalRow{
+			Utterance: record[0],
+			Expected:  strings.TrimSpace(record[1]),
+		}
+		if len(record) > 2 {
+			row.Start = strings.TrimSpace(record[2])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func printEvalReport(out io.Writer, total, correct int, accuracy float64, confusion map[string]map[string]int, unmatched []evalRow) {
+	fmt.Fprintf(out, "Accuracy: %v/%v (%.1f%%)\n\n", correct, total, accuracy*100)
+
+	labelSet := map[string]bool{}
+	for expected, predictions := range confusion {
+		labelSet[expected] = true
+		for predicted := range predictions {
+			labelSet[predicted] = true
+		}
+	}
+	labels := make([]string, 0, len(labelSet))
+	for label := range labelSet {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	fmt.Fprintln(out, "Confusion matrix (rows are expected, columns are predicted):")
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(table, "\t")
+	for _, label := range labels {
+		fmt.Fprintf(table, "%v\t", label)
+	}
+	fmt.Fprintln(table)
+	for _, expected := range labels {
+		if confusion[expected] == nil {
+			continue
+		}
+		fmt.Fprintf(table, "%v\t", expected)
+		for _, predicted := range labels {
+			fmt.Fprintf(table, "%v\t", confusion[expected][predicted])
+		}
+		fmt.Fprintln(table)
+	}
+	table.Flush()
+
+	if len(unmatched) > 0 {
+		fmt.Fprintf(out, "\nUnmatched utterances (%v):\n", len(unmatched))
+		for _, row := range unmatched {
+			fmt.Fprintf(out, "  %q expected %v\n", row.Utterance, row.Expected)
+		}
+	}
+}
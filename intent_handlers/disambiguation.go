@@ -0,0 +1,131 @@
+package intentHandlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/talkative-ai/brahman/nlu"
+)
+
+// DisambiguationMargin is how close in probability the best dialogs must be
+// for Brahman to ask which was meant instead of choosing one
+var DisambiguationMargin = 0.1
+
+// maxChoices is the most dialogs offered in a single "did you mean" prompt
+const maxChoices = 3
+
+// DialogChoice is a dialog offered to the user in a "did you mean" prompt
+type DialogChoice struct {
+	DialogKey string
+	Label     string
+	Global    bool `json:",omitempty"`
+}
+
+// ordinals map spoken positions to choice indexes
+// -1 refers to the last choice
+var ordinals = map[string]int{
+	"first":  0,
+	"one":    0,
+	"1":      0,
+	"former": 0,
+	"second": 1,
+	"two":    1,
+	"2":      1,
+	"third":  2,
+	"three":  2,
+	"3":      2,
+	"last":   -1,
+	"latter": -1,
+}
+
+// closeChoices picks the candidates within DisambiguationMargin of the best one
+// Candidates without an example phrase to describe them are left out,
+// and when that's the best one there's no choice offered so it wins outright
+// Expects candidates sorted best first
+func closeChoices(candidates []candidate) []DialogChoice {
+	choices := []DialogChoice{}
+	if len(candidates) < 2 {
+		return choices
+	}
+	best := candidates[0].Result.Intent.Probability
+	for i, c := range candidates {
+		if len(choices) == maxChoices || best-c.Result.Intent.Probability > DisambiguationMargin {
+			break
+		}
+		label := exampleFor(c.Context, c.Result.Intent.Name)
+		if label == "" && i == 0 {
+			return choices
+		}
+		if label == "" {
+			continue
+		}
+		choices = append(choices, DialogChoice{
+			DialogKey: c.Result.Intent.Name,
+			Label:     label,
+			Global:    c.Global,
+		})
+	}
+	return choices
+}
+
+// exampleFor returns the first training phrase of a dialog in a context
+func exampleFor(context, dialogKey string) string {
+	dataset, err := nlu.LoadDataset(context)
+	if err != nil {
+		fmt.Println("Error loading dataset", err)
+		return ""
+	}
+	return dataset.Intents[dialogKey].Example()
+}
+
+// listChoices joins the choice labels for speech, e.g. "a", "b" or "c"
+func listChoices(choices []DialogChoice) string {
+	labels := make([]string, len(choices))
+	for i, choice := range choices {
+		labels[i] = choice.Label
+	}
+	return listPhrases(labels)
+}
+
+// listPhrases quotes and joins phrases for speech, e.g. "a", "b" or "c"
+func listPhrases(phrases []string) string {
+	quoted := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		quoted[i] = fmt.Sprintf(`"%v"`, phrase)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return fmt.Sprintf("%v or %v", strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+}
+
+// resolveChoice matches the input against the pending choices, by position or by label
+// The pending choices are cleared either way
+func (s *Session) resolveChoice(rawInput string) *DialogChoice {
+	choices := s.Choices
+	s.Choices = nil
+	if len(choices) == 0 {
+		return nil
+	}
+
+	for _, word := range strings.Fields(strings.ToLower(rawInput)) {
+		if index, ok := ordinals[strings.Trim(word, ".,!?")]; ok {
+			if index < 0 {
+				index = len(choices) - 1
+			}
+			if index < len(choices) {
+				return &choices[index]
+			}
+		}
+	}
+
+	var best *DialogChoice
+	bestScore := 0.5
+	for i := range choices {
+		if score := nlu.Similarity(rawInput, choices[i].Label); score > bestScore {
+			best = &choices[i]
+			bestScore = score
+		}
+	}
+	return best
+}
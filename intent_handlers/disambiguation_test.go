@@ -0,0 +1,43 @@
+package intentHandlers
+
+import "testing"
+
+func TestResolveChoice(t *testing.T) {
+	choices := []DialogChoice{
+		{DialogKey: "a", Label: "open the door"},
+		{DialogKey: "b", Label: "take the key"},
+		{DialogKey: "c", Label: "talk to the guard"},
+	}
+
+	tests := []struct {
+		input   string
+		choices []DialogChoice
+		// want is the DialogKey of the expected choice, or empty for none
+		want string
+	}{
+		{"the first one", choices, "a"},
+		{"one", choices, "a"},
+		{"Second.", choices, "b"},
+		{"3", choices, "c"},
+		{"the last", choices, "c"},
+		{"the latter", choices[:2], "b"},
+		{"third", choices[:2], ""},
+		{"take the key", choices, "b"},
+		{"talk to guard", choices, "c"},
+		{"sing a song", choices, ""},
+		{"first", nil, ""},
+	}
+	for _, test := range tests {
+		session := &Session{Choices: test.choices}
+		got := session.resolveChoice(test.input)
+		if session.Choices != nil {
+			t.Errorf("resolveChoice(%q) left the choices pending", test.input)
+		}
+		switch {
+		case got == nil && test.want != "":
+			t.Errorf("resolveChoice(%q) = nil, want %q", test.input, test.want)
+		case got != nil && got.DialogKey != test.want:
+			t.Errorf("resolveChoice(%q) = %q, want %q", test.input, got.DialogKey, test.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/brahman/nlu"
+	"github.com/talkative-ai/core/models"
+	ssml "github.com/talkative-ai/go-ssml"
+)
+
+// ErrorKind classifies the errors which end a turn, so each platform can apologize appropriately
+type ErrorKind string
+
+const (
+	// ErrorNLUUnavailable means the input couldn't be parsed at all
+	ErrorNLUUnavailable ErrorKind = "nlu_unavailable"
+	// ErrorStateCorrupt means the conversation state couldn't be read
+	ErrorStateCorrupt ErrorKind = "state_corrupt"
+	// ErrorDialogMissing means compiled app data referenced by the state doesn't exist
+	ErrorDialogMissing ErrorKind = "dialog_missing"
+	// ErrorDatabase means Postgres or redis failed
+	ErrorDatabase ErrorKind = "database"
+	// ErrorInternal is anything else
+	ErrorInternal ErrorKind = "internal"
+)
+
+// TurnError is an error which ended a turn
+type TurnError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *TurnError) Error() string {
+	return fmt.Sprintf("talkative:%v: %v", e.Kind, e.Err)
+}
+
+// StateCorrupt wraps an error reading the conversation state
+func StateCorrupt(err error) error {
+	return &TurnError{Kind: ErrorStateCorrupt, Err: err}
+}
+
+// DialogMissing wraps an error fetching compiled app data
+func DialogMissing(err error) error {
+	return &TurnError{Kind: ErrorDialogMissing, Err: err}
+}
+
+// DatabaseFailure wraps an error from Postgres or redis
+func DatabaseFailure(err error) error {
+	return &TurnError{Kind: ErrorDatabase, Err: err}
+}
+
+// compiledDataFailure wraps an error fetching compiled app data from redis
+// A missing key means the app refers to something which wasn't compiled
+func compiledDataFailure(err error) error {
+	if err == goredis.Nil {
+		return DialogMissing(err)
+	}
+	return DatabaseFailure(err)
+}
+
+// KindOf classifies err
+// Errors which weren't classified where they occurred are internal
+func KindOf(err error) ErrorKind {
+	if nlu.IsUnavailable(err) {
+		return ErrorNLUUnavailable
+	}
+	if turnErr, ok := err.(*TurnError); ok {
+		return turnErr.Kind
+	}
+	return ErrorInternal
+}
+
+// errorResponses are the IntentResponses keys apologizing for each kind of error
+var errorResponses = map[ErrorKind]string{
+	ErrorNLUUnavailable: "nlu unavailable",
+	ErrorStateCorrupt:   "error state corrupt",
+	ErrorDialogMissing:  "error dialog missing",
+	ErrorDatabase:       "error database",
+	ErrorInternal:       "error internal",
+}
+
+// Apologize replaces the output of the turn with an apology for the error
+// It returns the kind of the error
+func Apologize(ctx context.Context, err error, message *models.AIRequest) ErrorKind {
+	kind := KindOf(err)
+	fmt.Printf("Error (%v): %v\n", kind, err)
+	message.OutputSSML = ssml.NewBuilder().Text(ChooseResponse(ctx, errorResponses[kind]))
+	return kind
+}
@@ -0,0 +1,86 @@
+package intentHandlers
+
+import (
+	"context"
+
+	"github.com/talkative-ai/core/models"
+)
+
+// Explanation records how InAppHandler chooses a dialog for an input
+// It lets authors see why an utterance did or didn't trigger their dialog
+type Explanation struct {
+	Input string
+	// ResolvedChoice is set when the input answered a pending "did you mean" prompt
+	ResolvedChoice *DialogChoice `json:",omitempty"`
+	// Contexts are the compiled dialog contexts queried with the NLU
+	Contexts []ContextExplanation
+	// Unknown are the catch-all dialogs looked up when nothing matched
+	Unknown []UnknownExplanation `json:",omitempty"`
+	// Choices are set when the input would be answered with a "did you mean" prompt
+	Choices []DialogChoice `json:",omitempty"`
+	// Winner is the dialog which would be triggered, empty when there's none
+	Winner string
+	// CatchAll is set when the Winner is a catch-all unknown dialog, as nothing matched
+	CatchAll bool `json:",omitempty"`
+}
+
+// ContextExplanation is a compiled dialog context and the dialogs the NLU matched within it
+type ContextExplanation struct {
+	Context    string
+	Source     string
+	Candidates []CandidateExplanation
+}
+
+// CandidateExplanation is a dialog matched by the NLU
+type CandidateExplanation struct {
+	Dialog      string
+	Probability float64
+	Threshold   float64
+	Passed      bool
+}
+
+// UnknownExplanation is a catch-all dialog lookup
+type UnknownExplanation struct {
+	Context string
+	Dialog  string
+}
+
+// Explain works out which dialog InAppHandler would trigger for the input, without triggering it
+func Explain(ctx context.Context, rawInput string, state *models.MutableAIRequestState) (*Explanation, error) {
+	session, err := LoadSession(state)
+	if err != nil {
+		return nil, err
+	}
+	ctx = session.withLocale(ctx)
+	explain := &Explanation{
+		Input:    rawInput,
+		Contexts: []ContextExplanation{},
+	}
+	if _, err := selectDialog(ctx, rawInput, state, session, explain); err != nil {
+		return nil, err
+	}
+	return explain, nil
+}
+
+func (e *Explanation) recordCandidates(contexts, sources []string, candidates []candidate, t thresholds, state *models.MutableAIRequestState) {
+	for i, key := range contexts {
+		explained := ContextExplanation{
+			Context:    key,
+			Source:     sources[i],
+			Candidates: []CandidateExplanation{},
+		}
+		for _, c := range candidates {
+			if c.Context != key {
+				continue
+			}
+			threshold := t.For(state.Zone, c.Result.Intent.Name)
+			explained.Candidates = append(explained.Candidates, CandidateExplanation{
+				Dialog:      c.Result.Intent.Name,
+				Probability: c.Result.Intent.Probability,
+				Threshold:   threshold,
+				Passed:      c.Result.Intent.Probability > threshold,
+			})
+		}
+		e.Contexts = append(e.Contexts, explained)
+	}
+}
@@ -0,0 +1,57 @@
+package intentHandlers
+
+import (
+	"context"
+
+	"github.com/talkative-ai/core/models"
+)
+
+// IntentBack asks to return to the previous dialog of the app
+// It's handled by the routes, which replay the previous response once the state is restored
+const IntentBack = "app.back"
+
+// MaxHistory is how many visited dialogs are remembered for going back
+var MaxHistory = 20
+
+// pushHistory remembers the state before a dialog runs, dropping the oldest beyond MaxHistory
+func (s *Session) pushHistory(state models.MutableAIRequestState) {
+	s.History = append(s.History, state)
+	if len(s.History) > MaxHistory {
+		s.History = s.History[len(s.History)-MaxHistory:]
+	}
+}
+
+// GoBack restores the state from before the last dialog of the app
+// It reports whether there was anywhere to go back to. If so, the caller should
+// replay the restored PreviousResponse, otherwise an explanation is output
+func GoBack(ctx context.Context, message *models.AIRequest) (bool, error) {
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return false, err
+	}
+	ctx = session.withLocale(ctx)
+
+	if len(session.History) == 0 {
+		message.OutputSSML.Text(ChooseResponse(ctx, "no history"))
+		return false, nil
+	}
+
+	last := len(session.History) - 1
+	sessionID := message.State.SessionID
+	message.State = session.History[last]
+	message.State.SessionID = sessionID
+	session.History = session.History[:last]
+	session.Choices = nil
+
+	return true, session.Save(&message.State)
+}
+
+// clearHistory forgets the visited dialogs, e.g. when the app restarts
+func clearHistory(state *models.MutableAIRequestState) error {
+	session, err := LoadSession(state)
+	if err != nil {
+		return err
+	}
+	session.History = nil
+	return session.Save(state)
+}
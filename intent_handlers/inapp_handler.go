@@ -1,64 +1,19 @@
 package intentHandlers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
 
-	"github.com/talkative-ai/snips-nlu-types"
-
-	goredis "github.com/go-redis/redis"
 	"github.com/talkative-ai/core/db"
 	"github.com/talkative-ai/core/models"
 	"github.com/talkative-ai/core/redis"
 	uuid "github.com/talkative-ai/go.uuid"
 )
 
-func MatchIntent(key, query string) (*snips.Result, error) {
-
-	var result snips.Result
-
-	data := url.Values{}
-	data.Set("query", query)
-	// Note the context here is set to App, rather than Talkative
-	// because this isn't a conversation with Talkative,
-	// it's a conversation with the app
-	data.Set("context", key)
-
-	rq, err := http.NewRequest("POST", fmt.Sprintf("http://kalidasa:8080/v1/parse"), strings.NewReader(data.Encode()))
-	if err != nil {
-		fmt.Println("Error in TrainData", err)
-		// TODO: Handle errors
-	}
-	rq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	rq.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
-
-	client := http.Client{}
-	resp, err := client.Do(rq)
-
-	rawResponse, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-		// TODO: Handle errors
-	}
-
-	json.Unmarshal(rawResponse, &result)
-
-	return &result, nil
-}
-
-func InAppHandler(rawInput string, message *models.AIRequest) error {
+func InAppHandler(ctx context.Context, rawInput string, message *models.AIRequest) error {
 	projectID := message.State.ProjectID
-	pubID := message.State.PubID
-
-	fmt.Printf("InApp Message: %+v\nSTATE = %+v\n", rawInput, message.State)
 
-	var dialogID string
-	eventIDChan := make(chan uuid.UUID)
+	eventIDChan := make(chan uuid.UUID, 1)
 	if !message.State.Demo {
 		go func() {
 			var newID uuid.UUID
@@ -70,68 +25,48 @@ func InAppHandler(rawInput string, message *models.AIRequest) error {
 		}()
 	}
 
-	if message.State.CurrentDialog != nil {
-		// Attempt to fetch a dialog relative to the current dialog.
-		// Otherwise known as dialog node children
-		// This is where conversational context works
-		currentDialogKey := *message.State.CurrentDialog
-		split := strings.Split(currentDialogKey, ":")
-		currentDialogID := split[len(split)-1]
-		input := models.DialogInput(rawInput)
-		result, err := MatchIntent(models.KeynavCompiledDialogNode(pubID, currentDialogID), input.Prepared())
-		if err != nil {
-			return err
-		}
-		// TODO: Generalize probability threshold
-		if result.Intent.Probability > 0.8 {
-			dialogID = result.Intent.Name
-		}
-		fmt.Printf("Result in current dialog, %+v\n", result)
-	} else {
-		// If there is no current dialog, then we scan all "root dialogs"
-		// for the actors within the Zone
-		// This is where conversations begin
-		for _, actorID := range message.State.ZoneActors[message.State.Zone] {
-			input := models.DialogInput(rawInput)
-			result, err := MatchIntent(models.KeynavCompiledDialogRootWithinActor(pubID, actorID), input.Prepared())
-			fmt.Printf("Result in root dialogs attempt: %+v\n", result)
-			if err != nil {
-				return err
-			}
-			// TODO: Generalize probability threshold
-			if result.Intent.Probability > 0.8 {
-				dialogID = result.Intent.Name
-				fmt.Printf("Result in root dialogs, %+v\n", result)
-				break
-			}
-		}
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return err
 	}
+	ctx = session.withLocale(ctx)
+	// Playing on rather than answering the offer to resume means starting over
+	session.ResumeOffered = false
 
-	// There were no dialogs at all with the given input
-	// So we check to see if there's a "catch-all" unknown dialog handler
-	if dialogID == "" {
-		fmt.Println("There were no dialogs.")
-		if message.State.CurrentDialog != nil {
-			currentDialogKey := *message.State.CurrentDialog
-			split := strings.Split(currentDialogKey, ":")
-			currentDialogID := split[len(split)-1]
-			v := redis.Instance.Get(models.KeynavCompiledDialogNodeUnknown(pubID, currentDialogID))
-			if v.Err() == nil || v.Err() == goredis.Nil {
-				dialogID = v.Val()
-			} else {
-				v.Err()
-			}
-		} else {
-			for _, actorID := range message.State.ZoneActors[message.State.Zone] {
-				v := redis.Instance.Get(models.KeynavCompiledDialogRootUnknownWithinActor(pubID, actorID))
-				if v.Err() == nil || v.Err() == goredis.Nil {
-					dialogID = v.Val()
-					break
-				} else {
-					v.Err()
-				}
-			}
+	trace := traceFrom(ctx)
+	selected, err := selectDialog(ctx, rawInput, &message.State, session, trace.explanation(rawInput))
+	if err != nil {
+		return err
+	}
+
+	// Several dialogs are about as likely as each other,
+	// so rather than guess we ask which one was meant
+	if len(selected.Choices) > 1 {
+		session.Choices = selected.Choices
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "did you mean"), listChoices(selected.Choices)))
+		return session.Save(&message.State)
+	}
+
+	dialogID := selected.DialogKey
+	if trace != nil {
+		trace.Dialog = dialogID
+	}
+	var suggestions []string
+	if selected.CatchAll {
+		// The author's catch-all answers, but the input still wasn't understood,
+		// so repeated misses lead to suggestions as they do without one
+		session.Misses++
+		if session.Misses >= MissesBeforeSuggestions {
+			suggestions = nextExamples(&message.State, maxChoices)
 		}
+	} else if dialogID != "" {
+		session.Misses = 0
+	}
+	if dialogID != "" {
+		session.pushHistory(message.State)
+	}
+	if err := session.Save(&message.State); err != nil {
+		return err
 	}
 
 	// Still nothing, so abort with a default unknown response
@@ -144,34 +79,92 @@ func InAppHandler(rawInput string, message *models.AIRequest) error {
 
 	dialogBinary, err := redis.Instance.Get(dialogID).Bytes()
 	if err != nil {
-		return err
+		return compiledDataFailure(err)
 	}
 	stateComms := make(chan models.AIRequest, 1)
-	defer close(stateComms)
+	abandoned := false
+	defer func() {
+		// Closing would resume abandoned logic, with an empty state
+		if !abandoned {
+			close(stateComms)
+		}
+	}()
 	dialogEnd := dialogBinary[0] == 0
 	dialogBinary = dialogBinary[1:]
 	if dialogEnd {
-		message.State.CurrentDialog = nil
+		// An app-wide command which ends leaves the player where they were
+		if !selected.Global {
+			message.State.CurrentDialog = nil
+		}
 	} else {
 		message.State.CurrentDialog = &dialogID
 	}
+	if runawayExceeded(projectID) {
+		// Earlier turns left too much of the project's logic running, so none more is started
+		limitExceeded(ctx, message, trace, eventIDChan, LimitRunaway)
+		return nil
+	}
+	logicCtx, cancel := context.WithTimeout(ctx, TurnLimits.Budget)
+	defer cancel()
+
 	stateChange := false
+	exceeded := ""
+	bundles := 0
+	listener := outputListenerFrom(ctx)
 	result := models.LogicLazyEval(stateComms, dialogBinary)
-	for res := range result {
-		if res.Error != nil {
-			return err
-		}
-		bundleBinary, err := redis.Instance.Get(res.Value).Bytes()
-		if err != nil {
-			return err
-		}
-		err = models.ActionBundleEval(message, bundleBinary)
-		if err != nil {
-			return err
+evaluation:
+	for {
+		select {
+		case res, ok := <-result:
+			if !ok {
+				break evaluation
+			}
+			if res.Error != nil {
+				trace.recordLogicError(res.Error)
+				return res.Error
+			}
+			if bundles == TurnLimits.MaxBundles {
+				exceeded = LimitBundles
+				break evaluation
+			}
+			bundles++
+			outputBefore := message.OutputSSML.Raw()
+			before := trace.snapshot(message.State)
+			bundleBinary, err := redis.Instance.Get(res.Value).Bytes()
+			if err != nil {
+				trace.recordBundle(res.Value, before, message.State, err)
+				return compiledDataFailure(err)
+			}
+			err = models.ActionBundleEval(message, bundleBinary)
+			trace.recordBundle(res.Value, before, message.State, err)
+			if err != nil {
+				return err
+			}
+			stateChange = true
+			if outputLength(message.OutputSSML.Raw()) > TurnLimits.MaxOutput {
+				truncateOutput(message, outputBefore, TurnLimits.MaxOutput)
+				exceeded = LimitOutput
+				break evaluation
+			}
+			if listener != nil {
+				listener(outputAdded(outputBefore, message.OutputSSML.Raw()))
+			}
+			stateComms <- *message
+		case <-logicCtx.Done():
+			exceeded = LimitBudget
+			break evaluation
 		}
-		stateComms <- *message
-		stateChange = true
 	}
+
+	if exceeded != "" {
+		// The turn ends with whatever was output so far
+		// The logic is abandoned where it stands, as core has no way to cancel it
+		abandoned = true
+		abandon(projectID, exceeded, func() { <-result })
+		limitExceeded(ctx, message, trace, eventIDChan, exceeded)
+		return nil
+	}
+
 	// TODO: Reenable
 	stateChange = false
 	if stateChange && !message.State.Demo {
@@ -180,5 +173,9 @@ func InAppHandler(rawInput string, message *models.AIRequest) error {
 		go db.Instance.QueryRow(`INSERT INTO event_state_change ("EventUserActionID", "StateObject") VALUES ($1, $2)`, newID, stateObject)
 	}
 
+	if len(suggestions) > 0 {
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "unknown suggestions"), listPhrases(suggestions)))
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package intentHandlers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -8,7 +9,6 @@ import (
 	"github.com/talkative-ai/go.uuid"
 	snips "github.com/talkative-ai/snips-nlu-types"
 
-	"github.com/talkative-ai/core/common"
 	"github.com/talkative-ai/core/db"
 	"github.com/talkative-ai/core/models"
 	"github.com/talkative-ai/core/redis"
@@ -18,6 +18,7 @@ import (
 type RandomStringCollection map[string][]string
 
 // IntentResponses provide a variety of responses to generic requests
+// These are the English responses, see LocalizedResponses for other languages
 // TODO: Consider storing these as a database entry or external file so there's no deploy required
 // with every single update?
 var IntentResponses = RandomStringCollection{
@@ -26,6 +27,13 @@ var IntentResponses = RandomStringCollection{
 		"Sorry, I don't think I get that.",
 		"That doesn't make sense to me, sorry.",
 	},
+	"unknown hint": []string{
+		" Try saying 'help' if you're unsure what to do.",
+	},
+	"unknown suggestions": []string{
+		" Here are some things you could say: %v.",
+		" You could try saying %v.",
+	},
 	"hint actions after list.apps": []string{
 		"Or would you like to hear some genres?",
 		"There's a lot of genres too.",
@@ -42,19 +50,160 @@ var IntentResponses = RandomStringCollection{
 	"instructions": []string{
 		"You can say \"list apps\" to hear a list of user-generated content. Otherwise, try asking 'What is Talkative?'",
 	},
+	"nlu unavailable": []string{
+		"Sorry, I'm having trouble understanding right now. Could you say that again in a moment?",
+		"Sorry, my hearing is a bit off right now. Please try that again shortly.",
+	},
+	"did you mean": []string{
+		"Did you mean %v?",
+		"Sorry, do you mean %v?",
+	},
 	"talkative info": []string{
 		`Talkative is a platform to create, publish, and play apps such as interactive stories.
 		Talkative is free to use and generate content for. Learn more at our website, www.talkative.ai!
 		To hear a list of apps, try saying "list apps"`,
 	},
+	"list apps": []string{
+		`Some available apps to play are: `,
+	},
+	"list apps separator": []string{
+		", another app is ",
+	},
+	"list apps instructions": []string{
+		`. To play an app, say "Let's play" and then the name of the app.`,
+	},
+	"app not found": []string{
+		"Cannot find that app.",
+	},
+	"app problem": []string{
+		"Sorry, there was a problem.",
+	},
+	"app found": []string{
+		"Okay, found it.",
+	},
+	"app doesn't exist": []string{
+		"Sorry, that one doesn't exist yet! Try saying 'help' if you're unsure what to do next.",
+	},
+	"app starting": []string{
+		"Okay, starting %v. Have fun!",
+	},
+	"app resume offer": []string{
+		` You have progress saved from last time. Say "continue" to pick up where you left off, or "start over" to begin again.`,
+	},
+	"app resuming": []string{
+		"Okay, picking up where you left off.",
+		"Welcome back! Let's continue where you left off.",
+	},
+	"app starting over": []string{
+		"Okay, starting over from the beginning.",
+	},
+	"saves unavailable": []string{
+		"Sorry, saving isn't available here.",
+	},
+	"save name missing": []string{
+		`Please say the name of the save too, such as "save game as castle run".`,
+	},
+	"saves full": []string{
+		"You already have %v saves. Delete one before making another, or save over an existing one.",
+	},
+	"game saved": []string{
+		`Okay, saved as "%v".`,
+		`Done, your progress is saved as "%v".`,
+	},
+	"save not found": []string{
+		`Sorry, there's no save called "%v".`,
+	},
+	"save loaded": []string{
+		`Okay, loaded "%v".`,
+	},
+	"save deleted": []string{
+		`Okay, deleted "%v".`,
+	},
+	"saves list": []string{
+		"Your saves are %v.",
+	},
+	"no saves": []string{
+		`You don't have any saves yet. Say "save game as" and then a name to make one.`,
+	},
+	"app stopping": []string{
+		`
+		Okay, stopping the app now. You're back to the main menu.
+		If you're not sure what to do, say "help"`,
+	},
+	"app restarting": []string{
+		`Okay, restarting now...`,
+	},
+	"app restart confirm": []string{
+		`All of your progress will be lost forever. If you're sure, say "I'm sure". Otherwise, say "cancel".`,
+	},
+	"app restart cancelled": []string{
+		`Okay, you've cancelled restarting. Try saying 'help' if you're unsure what to do next.`,
+	},
+	"talkative help": []string{
+		`
+		You can say "list apps" to hear what's available,
+		"help" to hear this help menu,
+		and "quit" to leave.`,
+	},
+	"app help demo": []string{
+		`
+			You can say "repeat that" to repeat the last thing from the app,
+			and "help" to hear this help menu.`,
+	},
+	"you could say": []string{
+		"You could say %v.",
+		"Try saying %v.",
+	},
+	"no input": []string{
+		"Are you still there?",
+		"Sorry, I didn't hear anything.",
+	},
+	"no input again": []string{
+		"I still didn't hear anything.",
+		"Sorry, I still can't hear you.",
+	},
+	"no input end": []string{
+		"It seems you've stepped away, so let's stop here for now. Talk to you later!",
+	},
+	"no history": []string{
+		"There's nowhere to go back to yet.",
+	},
+	"error state corrupt": []string{
+		"Sorry, I lost track of where we were. Let's start again from the main menu.",
+	},
+	"error dialog missing": []string{
+		"Sorry, this part of the app seems to be missing. Try saying something else.",
+	},
+	"error database": []string{
+		"Sorry, I'm having trouble remembering things right now. Please try again in a moment.",
+	},
+	"error internal": []string{
+		"Sorry, something went wrong. Please try that again.",
+	},
+	"app help": []string{
+		`
+		You can say "repeat that" to repeat the last thing from the app,
+		"go back" to return to where you were before,
+		"save game as" and then a name to save your progress,
+		"load" and then the name of a save to pick it up again,
+		"list saves" to hear your saves,
+		"stop app" to leave the current app,
+		"restart app" to start from the beginning erasing all of your progress,
+		and "help" to hear this help menu.`,
+	},
 }
 
 // ErrIntentNoMatch occurs when an intent handler does not match the current context
 // For example, a user saying "cancel" for no reason
 var ErrIntentNoMatch = fmt.Errorf("talkative:no_match")
 
+// IntentUnavailable is dispatched by the routes in place of an NLU intent
+// when the input could not be parsed at all
+const IntentUnavailable = "talkative.unavailable"
+
 // IntentHandler is a function signature for handling api.ai requests
-type IntentHandler func(*snips.Result, *models.AIRequest) error
+// The context carries the request deadline and the locale of the user
+type IntentHandler func(context.Context, *snips.Result, *models.AIRequest) error
 
 // List maps ApiAi intents to functions
 var List = map[string]IntentHandler{
@@ -67,27 +216,36 @@ var List = map[string]IntentHandler{
 	"app.stop":                 AppStop,
 	"app.restart":              AppRestart,
 	"app.help":                 AppHelp,
+	"app.resume":               AppResume,
+	"AMAZON.ResumeIntent":      AppResume,
+	"app.start_over":           AppStartOver,
+	"AMAZON.StartOverIntent":   AppStartOver,
+	"app.save":                 AppSave,
+	"app.load":                 AppLoad,
+	"app.saves.list":           AppListSaves,
+	"app.saves.delete":         AppDeleteSave,
 	"AMAZON.HelpIntent":        AppHelp,
 	"confirm":                  ConfirmHandler,
 	"cancel":                   CancelHandler,
+	IntentUnavailable:          Unavailable,
 }
 
 // Welcome IntentHandler provides an introduction to Talkative
-func Welcome(input *snips.Result, message *models.AIRequest) error {
+func Welcome(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
 	message.OutputSSML = message.OutputSSML.
-		Text(common.ChooseString(IntentResponses["introduce"])).
-		Text(common.ChooseString(IntentResponses["instructions"]))
+		Text(ChooseResponse(ctx, "introduce")).
+		Text(ChooseResponse(ctx, "instructions"))
 	return nil
 }
 
 // Info IntentHandler provides additional information on Talkative
-func TalkativeInfo(input *snips.Result, message *models.AIRequest) error {
-	message.OutputSSML = message.OutputSSML.Text(common.ChooseString(IntentResponses["talkative info"]))
+func TalkativeInfo(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "talkative info"))
 	return nil
 }
 
 // ListApps IntentHandler provides additional information on Talkative
-func TalkativeListApps(input *snips.Result, message *models.AIRequest) error {
+func TalkativeListApps(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
 
 	var items []models.Project
 	_, err := db.DBMap.Select(&items, `
@@ -100,29 +258,60 @@ func TalkativeListApps(input *snips.Result, message *models.AIRequest) error {
 		LIMIT 5
 	`)
 	if err != nil {
-		return err
+		return DatabaseFailure(err)
 	}
 
-	message.OutputSSML.Text(`Some available apps to play are: `)
+	message.OutputSSML.Text(ChooseResponse(ctx, "list apps"))
 	for i := 0; i < len(items); i++ {
 		if i > 0 {
-			message.OutputSSML.Text(", another app is ")
+			message.OutputSSML.Text(ChooseResponse(ctx, "list apps separator"))
 		}
 		message.OutputSSML.Text(fmt.Sprintf("'%v'", items[i].Title))
 	}
-	message.OutputSSML.Text(`. To play an app, say "Let's play" and then the name of the app.`)
+	message.OutputSSML.Text(ChooseResponse(ctx, "list apps instructions"))
 	return nil
 }
 
+// MissesBeforeSuggestions is how many misunderstood turns in a row it takes
+// before Unknown suggests what could be said instead of only apologizing
+var MissesBeforeSuggestions = 2
+
 // Unknown IntentHandler handles all unknown intents
-func Unknown(input *snips.Result, message *models.AIRequest) error {
-	message.OutputSSML = message.OutputSSML.Text(common.ChooseString(IntentResponses["unknown"])).
-		Text(" Try saying 'help' if you're unsure what to do.")
+// After repeated misses within an app, it suggests phrases from the dialogs available next
+func Unknown(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		// Still apologize, just without the suggestions
+		fmt.Println("Error loading session", err)
+		session = &Session{}
+	}
+	ctx = session.withLocale(ctx)
+	session.Misses++
+
+	message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "unknown"))
+
+	var examples []string
+	if session.Misses >= MissesBeforeSuggestions {
+		examples = nextExamples(&message.State, maxChoices)
+	}
+	if len(examples) > 0 {
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "unknown suggestions"), listPhrases(examples)))
+	} else {
+		message.OutputSSML.Text(ChooseResponse(ctx, "unknown hint"))
+	}
+
+	return session.Save(&message.State)
+}
+
+// Unavailable IntentHandler apologizes when the input couldn't be understood at all,
+// such as when the NLU is down. The state is left as is so the user can simply try again
+func Unavailable(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "nlu unavailable"))
 	return nil
 }
 
 // DemoApp enables users to demo their own app before publishing it
-func TalkativeAppDemo(input *snips.Result, message *models.AIRequest) error {
+func TalkativeAppDemo(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
 	project := models.Project{}
 	err := db.DBMap.SelectOne(&project, `
 		SELECT "ID"
@@ -130,25 +319,20 @@ func TalkativeAppDemo(input *snips.Result, message *models.AIRequest) error {
 		WHERE LOWER("Title")=LOWER($1)
 	`, input.SlotsMappedByName()["appName"].RawValue)
 	if err != nil && err == sql.ErrNoRows {
-		message.OutputSSML = message.OutputSSML.Text("Cannot find that app.")
+		message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "app not found"))
 		return nil
 	} else if err != nil {
-		message.OutputSSML = message.OutputSSML.Text("Sorry, there was a problem.")
-		return err
+		message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "app problem"))
+		return DatabaseFailure(err)
 	}
 	projectID := project.ID
-	message.OutputSSML = message.OutputSSML.Text("Okay, found it.")
-	message.State.ProjectID = projectID
-	message.State.SessionID = uuid.NewV4()
-	message.State.PubID = fmt.Sprintf("demo:%v", projectID.String())
-	message.State.Demo = true
-	var setup models.RAResetApp
-	setup.Execute(message)
+	message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "app found"))
+	StartApp(ctx, message, projectID, true)
 	return nil
 }
 
 // InitializeApp IntentHandler will begin a specified app if it exists
-func TalkativeInitialize(input *snips.Result, message *models.AIRequest) error {
+func TalkativeInitialize(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
 
 	var appName string
 
@@ -158,85 +342,128 @@ func TalkativeInitialize(input *snips.Result, message *models.AIRequest) error {
 
 	projectID := uuid.FromStringOrNil(redis.Instance.HGet(models.KeynavGlobalMetaProjects(), strings.ToUpper(appName)).Val())
 	if projectID == uuid.Nil {
-		message.OutputSSML = message.OutputSSML.Text("Sorry, that one doesn't exist yet! Try saying 'help' if you're unsure what to do next.")
+		message.OutputSSML = message.OutputSSML.Text(ChooseResponse(ctx, "app doesn't exist"))
 		return nil
 	}
-	message.State.ProjectID = projectID
-	message.State.PubID = projectID.String()
-	message.State.SessionID = uuid.NewV4()
-	message.OutputSSML = message.OutputSSML.Text(fmt.Sprintf("Okay, starting %v. Have fun!", appName))
+	message.OutputSSML = message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "app starting"), appName))
+	StartApp(ctx, message, projectID, false)
+	return nil
+}
+
+// StartApp begins the app from scratch in a new session
+// Demos run the app as last compiled in the workbench rather than as published
+// A user with saved progress is offered to resume it
+func StartApp(ctx context.Context, message *models.AIRequest, projectID uuid.UUID, demo bool) {
+	pubID := projectID.String()
+	if demo {
+		pubID = DemoPubIDPrefix + pubID
+	}
+	message.State = models.MutableAIRequestState{
+		ProjectID: projectID,
+		SessionID: uuid.NewV4(),
+		PubID:     LocalizedPubID(ctx, pubID),
+		Demo:      demo,
+	}
 	var setup models.RAResetApp
 	setup.Execute(message)
-	return nil
+	if err := OfferResume(ctx, message); err != nil {
+		// The app has started regardless, just without the offer
+		fmt.Println("Error offering to resume", err)
+	}
 }
 
-func AppStop(input *snips.Result, runtimeState *models.AIRequest) error {
+// IsPublished reports whether the project has been published, so it can be played outside of demos
+func IsPublished(projectID uuid.UUID) (bool, error) {
+	var found int
+	err := db.Instance.QueryRow(`
+		SELECT 1
+		FROM published_workbench_projects
+		WHERE "ProjectID"=$1
+		LIMIT 1
+	`, projectID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, DatabaseFailure(err)
+	}
+	return true, nil
+}
+
+// IsAuthor reports whether the workbench user is a member of the team the project belongs to
+func IsAuthor(userID, projectID uuid.UUID) (bool, error) {
+	var found int
+	err := db.Instance.QueryRow(`
+		SELECT 1
+		FROM workbench_projects p
+		JOIN team_members tm
+		ON tm."TeamID" = p."TeamID"
+		WHERE p."ID"=$1 AND tm."UserID"=$2
+		LIMIT 1
+	`, projectID, userID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, DatabaseFailure(err)
+	}
+	return true, nil
+}
+
+func AppStop(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
 	if runtimeState.State.Demo {
 		return ErrIntentNoMatch
 	}
-	runtimeState.OutputSSML.Text(`
-		Okay, stopping the app now. You're back to the main menu.
-		If you're not sure what to do, say "help"`)
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app stopping"))
 	runtimeState.State = models.MutableAIRequestState{}
 	return nil
 }
 
-func AppRestart(input *snips.Result, runtimeState *models.AIRequest) error {
+func AppRestart(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
 	if runtimeState.State.Demo {
 		return ErrIntentNoMatch
 	}
 	if runtimeState.State.RestartRequested {
 		runtimeState.State.RestartRequested = false
-		runtimeState.OutputSSML.Text(`Okay, restarting now...`)
+		runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app restarting"))
 		var setup models.RAResetApp
 		setup.Execute(runtimeState)
-		return nil
+		return clearHistory(&runtimeState.State)
 	}
-	runtimeState.OutputSSML.Text(`All of your progress will be lost forever. If you're sure, say "I'm sure". Otherwise, say "cancel".`)
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app restart confirm"))
 	runtimeState.State.RestartRequested = true
 	// TODO: Manage restart requested here
 	return nil
 }
 
-func ConfirmHandler(input *snips.Result, runtimeState *models.AIRequest) error {
+func ConfirmHandler(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
 	if !runtimeState.State.RestartRequested {
 		return ErrIntentNoMatch
 	}
 
-	runtimeState.OutputSSML.Text(`Okay, restarting now...`)
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app restarting"))
 	var setup models.RAResetApp
 	setup.Execute(runtimeState)
-	return nil
+	return clearHistory(&runtimeState.State)
 }
 
-func CancelHandler(input *snips.Result, runtimeState *models.AIRequest) error {
+func CancelHandler(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
 	if !runtimeState.State.RestartRequested {
 		return ErrIntentNoMatch
 	}
 
-	runtimeState.OutputSSML.Text(`Okay, you've cancelled restarting. Try saying 'help' if you're unsure what to do next.`)
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app restart cancelled"))
 	return nil
 }
 
-func TalkativeHelp(input *snips.Result, runtimeState *models.AIRequest) error {
-	runtimeState.OutputSSML.Text(`
-		You can say "list apps" to hear what's available,
-		"help" to hear this help menu,
-		and "quit" to leave.`)
+func TalkativeHelp(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "talkative help"))
 	return nil
 }
 
-func AppHelp(input *snips.Result, runtimeState *models.AIRequest) error {
+func AppHelp(ctx context.Context, input *snips.Result, runtimeState *models.AIRequest) error {
 	if runtimeState.State.Demo {
-		runtimeState.OutputSSML.Text(`
-			You can say "repeat that" to repeat the last thing from the app,
-			and "help" to hear this help menu.`)
+		runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app help demo"))
 		return nil
 	}
-	runtimeState.OutputSSML.Text(`
-		You can say "repeat that" to repeat the last thing from the app,
-		"stop app" to leave the current app,
-		"restart app" to start from the beginning erasing all of your progress,
-		and "help" to hear this help menu.`)
+	runtimeState.OutputSSML.Text(ChooseResponse(ctx, "app help"))
 	return nil
 }
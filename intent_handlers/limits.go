@@ -0,0 +1,137 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/talkative-ai/core/db"
+	"github.com/talkative-ai/core/models"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// Limits bound what the dialog logic of an app may do within a single turn,
+// so that a badly authored or malicious project can't loop forever or flood the player
+type Limits struct {
+	// MaxBundles is how many action bundles may be evaluated
+	MaxBundles int
+	// MaxOutput is how many characters may be output, not counting SSML markup
+	MaxOutput int
+	// Budget is how long the dialog logic may run
+	// The deadline of the request applies as well
+	Budget time.Duration
+	// MaxRunaway is how many abandoned evaluations of a project's logic may still be running
+	// Beyond it the project's logic isn't run at all until some of them end
+	MaxRunaway int
+}
+
+// TurnLimits are the limits applied to every turn within an app
+// They are configured on startup from MAX_BUNDLES_PER_TURN, MAX_OUTPUT_LENGTH,
+// TURN_BUDGET and MAX_RUNAWAY_EVALUATIONS
+var TurnLimits = Limits{
+	MaxBundles: 100,
+	MaxOutput:  8000,
+	Budget:     time.Second * 2,
+	MaxRunaway: 3,
+}
+
+// The limits as reported to analytics
+const (
+	LimitBundles = "bundles"
+	LimitOutput  = "output"
+	LimitBudget  = "budget"
+	LimitRunaway = "runaway"
+)
+
+// ssmlTag matches SSML markup, which isn't counted as output
+var ssmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// PlainText renders SSML as it would be displayed rather than spoken
+func PlainText(raw string) string {
+	return html.UnescapeString(ssmlTag.ReplaceAllString(raw, ""))
+}
+
+// outputLength is how many characters of the SSML are spoken or displayed
+func outputLength(raw string) int {
+	return utf8.RuneCountInString(PlainText(raw))
+}
+
+// truncateOutput cuts the output down to max characters
+// The output is cut back to before, as it was before the last bundle, so authored SSML stays whole
+// When the first bundle alone is too long there's no such boundary, and only its plain text is kept
+func truncateOutput(message *models.AIRequest, before string, max int) {
+	raw := message.OutputSSML.Raw()
+	if outputLength(raw) <= max {
+		return
+	}
+	if before != "" && outputLength(before) <= max {
+		message.OutputSSML = ssml.NewBuilder().Text(before)
+		return
+	}
+	text := []rune(PlainText(raw))
+	message.OutputSSML = ssml.NewBuilder().Text(html.EscapeString(string(text[:max])))
+}
+
+// limitExceeded ends the turn early with whatever was output so far, and reports the limit
+func limitExceeded(ctx context.Context, message *models.AIRequest, trace *Trace, eventIDChan <-chan uuid.UUID, limit string) {
+	if trace != nil {
+		trace.Limit = limit
+	}
+	if len(message.OutputSSML.Raw()) == 0 {
+		message.OutputSSML.Text(ChooseResponse(ctx, "app problem"))
+	}
+	if !message.State.Demo {
+		go reportLimitExceeded(eventIDChan, message.State.ProjectID, limit)
+	}
+}
+
+// runaway counts by project the abandoned evaluations of dialog logic which may still be running
+var runaway = struct {
+	sync.Mutex
+	counts map[uuid.UUID]int
+}{counts: map[uuid.UUID]int{}}
+
+// runawayExceeded reports whether the project has too many abandoned evaluations still running
+func runawayExceeded(projectID uuid.UUID) bool {
+	runaway.Lock()
+	defer runaway.Unlock()
+	return runaway.counts[projectID] >= TurnLimits.MaxRunaway
+}
+
+// abandon leaves behind an evaluation of the project's logic ended early by the limit
+// At the bundles and output limits the logic has just yielded, and waits for a state which is never sent,
+// so it no longer runs. At the budget it may still be running, and is counted until next returns
+// next waits for the logic to yield its next value or end, after which it is parked the same way
+func abandon(projectID uuid.UUID, limit string, next func()) {
+	if limit != LimitBudget {
+		return
+	}
+	runaway.Lock()
+	runaway.counts[projectID]++
+	runaway.Unlock()
+	go func() {
+		next()
+		runaway.Lock()
+		defer runaway.Unlock()
+		runaway.counts[projectID]--
+		if runaway.counts[projectID] == 0 {
+			delete(runaway.counts, projectID)
+		}
+	}()
+}
+
+// reportLimitExceeded records in analytics that a turn was ended by the limit
+// The event_limit_exceeded table is created by migrations/002_event_limit_exceeded.up.sql
+func reportLimitExceeded(eventIDChan <-chan uuid.UUID, projectID uuid.UUID, limit string) {
+	fmt.Printf("Project %v exceeded the %v limit\n", projectID, limit)
+	newID := <-eventIDChan
+	_, err := db.Instance.Exec(`INSERT INTO event_limit_exceeded ("EventUserActionID", "ProjectID", "Limit") VALUES ($1, $2, $3)`, newID, projectID, limit)
+	if err != nil {
+		fmt.Println("Error reporting exceeded limit", err)
+	}
+}
@@ -0,0 +1,69 @@
+package intentHandlers
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+func TestOutputLength(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want int
+	}{
+		{"", 0},
+		{"Hello there.", 12},
+		{`<audio src="https://example.com/a-very-long-file-name.mp3"/>`, 0},
+		{`Wait<break time="3s"/> for it.`, 12},
+		{"<emphasis>Ça va</emphasis>", 5},
+	}
+	for _, test := range tests {
+		if got := outputLength(test.raw); got != test.want {
+			t.Errorf("outputLength(%q) = %v, want %v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestAbandon(t *testing.T) {
+	projectID := uuid.NewV4()
+	releases := []chan bool{}
+	for i := 0; i < TurnLimits.MaxRunaway; i++ {
+		if runawayExceeded(projectID) {
+			t.Fatalf("runawayExceeded() after %v abandoned evaluations", i)
+		}
+		release := make(chan bool)
+		releases = append(releases, release)
+		abandon(projectID, LimitBudget, func() { <-release })
+	}
+	if !runawayExceeded(projectID) {
+		t.Fatalf("runawayExceeded() = false after %v abandoned evaluations", TurnLimits.MaxRunaway)
+	}
+	if runawayExceeded(uuid.NewV4()) {
+		t.Errorf("runawayExceeded() counts other projects")
+	}
+
+	close(releases[0])
+	deadline := time.Now().Add(time.Second)
+	for runawayExceeded(projectID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("runawayExceeded() still true after an evaluation ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, release := range releases[1:] {
+		close(release)
+	}
+}
+
+func TestAbandonParked(t *testing.T) {
+	projectID := uuid.NewV4()
+	parked := make(chan bool)
+	defer close(parked)
+	for _, limit := range []string{LimitBundles, LimitOutput, LimitBundles, LimitOutput} {
+		abandon(projectID, limit, func() { <-parked })
+		if runawayExceeded(projectID) {
+			t.Fatalf("runawayExceeded() after abandoning parked logic at the %v limit", limit)
+		}
+	}
+}
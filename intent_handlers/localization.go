@@ -0,0 +1,373 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/common"
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// LocalizedResponses are IntentResponses translated into other languages, mapped by language
+// Responses missing from a language fall back to English
+var LocalizedResponses = map[string]RandomStringCollection{
+	"es": RandomStringCollection{
+		"unknown": []string{
+			"No estoy seguro de entenderte.",
+			"Lo siento, creo que no lo entiendo.",
+			"Perdona, eso no tiene sentido para mí.",
+		},
+		"unknown hint": []string{
+			" Prueba a decir 'ayuda' si no sabes qué hacer.",
+		},
+		"unknown suggestions": []string{
+			" Estas son algunas cosas que puedes decir: %v.",
+			" Podrías probar a decir %v.",
+		},
+		"introduce": []string{
+			"Aquí Talkative. Espero que estés teniendo un buen día.",
+			"Hola, estás hablando con Talkative. Encantado de saber de ti.",
+		},
+		"instructions": []string{
+			"Puedes decir \"lista de apps\" para escuchar el contenido creado por los usuarios. Si no, prueba a preguntar '¿Qué es Talkative?'",
+		},
+		"nlu unavailable": []string{
+			"Lo siento, ahora mismo me cuesta entenderte. ¿Puedes repetirlo en un momento?",
+		},
+		"did you mean": []string{
+			"¿Quisiste decir %v?",
+			"Perdona, ¿te refieres a %v?",
+		},
+		"talkative info": []string{
+			`Talkative es una plataforma para crear, publicar y jugar apps como historias interactivas.
+			Talkative es gratis. ¡Descubre más en nuestra web, www.talkative.ai!
+			Para escuchar una lista de apps, prueba a decir "lista de apps"`,
+		},
+		"list apps": []string{
+			"Algunas apps disponibles son: ",
+		},
+		"list apps separator": []string{
+			", otra app es ",
+		},
+		"list apps instructions": []string{
+			`. Para jugar a una app, di "Vamos a jugar" y el nombre de la app.`,
+		},
+		"app not found": []string{
+			"No encuentro esa app.",
+		},
+		"app problem": []string{
+			"Lo siento, ha habido un problema.",
+		},
+		"app found": []string{
+			"Vale, la he encontrado.",
+		},
+		"app doesn't exist": []string{
+			"¡Lo siento, esa todavía no existe! Prueba a decir 'ayuda' si no sabes qué hacer.",
+		},
+		"app starting": []string{
+			"Vale, empezando %v. ¡Diviértete!",
+		},
+		"app resume offer": []string{
+			` Tienes progreso guardado de la última vez. Di "continuar" para seguir donde lo dejaste, o "empezar de nuevo" para volver al principio.`,
+		},
+		"app resuming": []string{
+			"Vale, seguimos donde lo dejaste.",
+		},
+		"app starting over": []string{
+			"Vale, empezamos desde el principio.",
+		},
+		"saves unavailable": []string{
+			"Lo siento, aquí no se puede guardar la partida.",
+		},
+		"save name missing": []string{
+			`Di también el nombre de la partida, por ejemplo "guardar partida como carrera del castillo".`,
+		},
+		"saves full": []string{
+			"Ya tienes %v partidas guardadas. Borra una antes de crear otra, o guarda sobre una existente.",
+		},
+		"game saved": []string{
+			`Vale, guardada como "%v".`,
+		},
+		"save not found": []string{
+			`Lo siento, no hay ninguna partida llamada "%v".`,
+		},
+		"save loaded": []string{
+			`Vale, cargada "%v".`,
+		},
+		"save deleted": []string{
+			`Vale, borrada "%v".`,
+		},
+		"saves list": []string{
+			"Tus partidas guardadas son %v.",
+		},
+		"no saves": []string{
+			`Todavía no tienes partidas guardadas. Di "guardar partida como" y un nombre para crear una.`,
+		},
+		"app stopping": []string{
+			`Vale, cerrando la app. Has vuelto al menú principal.
+			Si no sabes qué hacer, di "ayuda"`,
+		},
+		"app restarting": []string{
+			"Vale, reiniciando...",
+		},
+		"app restart confirm": []string{
+			`Perderás todo tu progreso para siempre. Si estás seguro, di "estoy seguro". Si no, di "cancelar".`,
+		},
+		"app restart cancelled": []string{
+			"Vale, has cancelado el reinicio. Prueba a decir 'ayuda' si no sabes qué hacer.",
+		},
+		"talkative help": []string{
+			`Puedes decir "lista de apps" para escuchar lo que hay disponible,
+			"ayuda" para escuchar este menú de ayuda,
+			y "salir" para irte.`,
+		},
+		"app help demo": []string{
+			`Puedes decir "repite" para repetir lo último que dijo la app,
+			y "ayuda" para escuchar este menú de ayuda.`,
+		},
+		"you could say": []string{
+			"Podrías decir %v.",
+			"Prueba a decir %v.",
+		},
+		"no input": []string{
+			"¿Sigues ahí?",
+			"Perdona, no he oído nada.",
+		},
+		"no input again": []string{
+			"Sigo sin oír nada.",
+		},
+		"no input end": []string{
+			"Parece que te has ido, así que lo dejamos aquí por ahora. ¡Hasta luego!",
+		},
+		"no history": []string{
+			"Todavía no hay ningún sitio al que volver.",
+		},
+		"error state corrupt": []string{
+			"Lo siento, he perdido el hilo de dónde estábamos. Volvamos a empezar desde el menú principal.",
+		},
+		"error dialog missing": []string{
+			"Lo siento, parece que falta esta parte de la app. Prueba a decir otra cosa.",
+		},
+		"error database": []string{
+			"Lo siento, ahora mismo me cuesta recordar las cosas. Inténtalo de nuevo en un momento.",
+		},
+		"error internal": []string{
+			"Lo siento, algo ha salido mal. Inténtalo de nuevo.",
+		},
+		"app help": []string{
+			`Puedes decir "repite" para repetir lo último que dijo la app,
+			"volver" para regresar a donde estabas antes,
+			"guardar partida como" y un nombre para guardar tu progreso,
+			"cargar" y el nombre de una partida para retomarla,
+			"mis partidas" para escuchar tus partidas guardadas,
+			"cerrar app" para salir de la app,
+			"reiniciar app" para empezar desde el principio borrando todo tu progreso,
+			y "ayuda" para escuchar este menú de ayuda.`,
+		},
+	},
+	"de": RandomStringCollection{
+		"unknown": []string{
+			"Ich bin mir nicht sicher, ob ich das verstehe.",
+			"Entschuldigung, das habe ich nicht verstanden.",
+			"Das ergibt für mich leider keinen Sinn.",
+		},
+		"unknown hint": []string{
+			" Sag 'Hilfe', wenn du nicht weiterweißt.",
+		},
+		"unknown suggestions": []string{
+			" Du könntest zum Beispiel Folgendes sagen: %v.",
+			" Versuch es mal mit %v.",
+		},
+		"introduce": []string{
+			"Hier spricht Talkative. Ich hoffe, du hast einen schönen Tag.",
+			"Hallo, hier ist Talkative. Schön, von dir zu hören.",
+		},
+		"instructions": []string{
+			"Sag \"Apps auflisten\", um von Nutzern erstellte Inhalte zu hören. Oder frag 'Was ist Talkative?'",
+		},
+		"nlu unavailable": []string{
+			"Entschuldigung, ich habe gerade Schwierigkeiten, dich zu verstehen. Kannst du das gleich noch einmal sagen?",
+		},
+		"did you mean": []string{
+			"Meintest du %v?",
+			"Entschuldigung, meinst du %v?",
+		},
+		"talkative info": []string{
+			`Talkative ist eine Plattform zum Erstellen, Veröffentlichen und Spielen von Apps wie interaktiven Geschichten.
+			Talkative ist kostenlos. Mehr erfährst du auf unserer Webseite, www.talkative.ai!
+			Um eine Liste der Apps zu hören, sag "Apps auflisten"`,
+		},
+		"list apps": []string{
+			"Einige verfügbare Apps sind: ",
+		},
+		"list apps separator": []string{
+			", eine weitere App ist ",
+		},
+		"list apps instructions": []string{
+			`. Um eine App zu spielen, sag "Lass uns spielen" und dann den Namen der App.`,
+		},
+		"app not found": []string{
+			"Diese App kann ich nicht finden.",
+		},
+		"app problem": []string{
+			"Entschuldigung, da gab es ein Problem.",
+		},
+		"app found": []string{
+			"Okay, gefunden.",
+		},
+		"app doesn't exist": []string{
+			"Entschuldigung, die gibt es noch nicht! Sag 'Hilfe', wenn du nicht weiterweißt.",
+		},
+		"app starting": []string{
+			"Okay, %v startet. Viel Spaß!",
+		},
+		"app resume offer": []string{
+			` Du hast noch einen Spielstand vom letzten Mal. Sag "weiter", um dort weiterzumachen, oder "von vorne", um neu anzufangen.`,
+		},
+		"app resuming": []string{
+			"Okay, es geht da weiter, wo du aufgehört hast.",
+		},
+		"app starting over": []string{
+			"Okay, wir fangen von vorne an.",
+		},
+		"saves unavailable": []string{
+			"Entschuldigung, hier kann leider nicht gespeichert werden.",
+		},
+		"save name missing": []string{
+			`Sag bitte auch den Namen des Spielstands, zum Beispiel "Spiel speichern als Burglauf".`,
+		},
+		"saves full": []string{
+			"Du hast bereits %v Spielstände. Lösche einen, bevor du einen neuen anlegst, oder überschreibe einen vorhandenen.",
+		},
+		"game saved": []string{
+			`Okay, gespeichert als "%v".`,
+		},
+		"save not found": []string{
+			`Entschuldigung, es gibt keinen Spielstand namens "%v".`,
+		},
+		"save loaded": []string{
+			`Okay, "%v" geladen.`,
+		},
+		"save deleted": []string{
+			`Okay, "%v" gelöscht.`,
+		},
+		"saves list": []string{
+			"Deine Spielstände sind %v.",
+		},
+		"no saves": []string{
+			`Du hast noch keine Spielstände. Sag "Spiel speichern als" und dann einen Namen, um einen anzulegen.`,
+		},
+		"app stopping": []string{
+			`Okay, die App wird beendet. Du bist zurück im Hauptmenü.
+			Wenn du nicht weiterweißt, sag "Hilfe"`,
+		},
+		"app restarting": []string{
+			"Okay, starte neu...",
+		},
+		"app restart confirm": []string{
+			`Dein gesamter Fortschritt geht für immer verloren. Wenn du dir sicher bist, sag "Ich bin sicher". Sonst sag "Abbrechen".`,
+		},
+		"app restart cancelled": []string{
+			"Okay, der Neustart wurde abgebrochen. Sag 'Hilfe', wenn du nicht weiterweißt.",
+		},
+		"talkative help": []string{
+			`Du kannst "Apps auflisten" sagen, um zu hören, was verfügbar ist,
+			"Hilfe", um dieses Hilfemenü zu hören,
+			und "Beenden", um zu gehen.`,
+		},
+		"app help demo": []string{
+			`Du kannst "Wiederholen" sagen, um das Letzte aus der App noch einmal zu hören,
+			und "Hilfe", um dieses Hilfemenü zu hören.`,
+		},
+		"you could say": []string{
+			"Du könntest %v sagen.",
+			"Sag zum Beispiel %v.",
+		},
+		"no input": []string{
+			"Bist du noch da?",
+			"Entschuldigung, ich habe nichts gehört.",
+		},
+		"no input again": []string{
+			"Ich höre immer noch nichts.",
+		},
+		"no input end": []string{
+			"Du scheinst weg zu sein, also machen wir hier erst einmal Schluss. Bis später!",
+		},
+		"no history": []string{
+			"Es gibt noch nichts, wohin du zurückgehen kannst.",
+		},
+		"error state corrupt": []string{
+			"Entschuldigung, ich habe den Faden verloren. Fangen wir noch einmal im Hauptmenü an.",
+		},
+		"error dialog missing": []string{
+			"Entschuldigung, dieser Teil der App scheint zu fehlen. Versuch etwas anderes zu sagen.",
+		},
+		"error database": []string{
+			"Entschuldigung, ich habe gerade Probleme, mich an Dinge zu erinnern. Bitte versuch es gleich noch einmal.",
+		},
+		"error internal": []string{
+			"Entschuldigung, da ist etwas schiefgelaufen. Bitte versuch es noch einmal.",
+		},
+		"app help": []string{
+			`Du kannst "Wiederholen" sagen, um das Letzte aus der App noch einmal zu hören,
+			"Zurück", um dorthin zurückzukehren, wo du vorher warst,
+			"Spiel speichern als" und einen Namen, um deinen Fortschritt zu speichern,
+			"Laden" und den Namen eines Spielstands, um dort weiterzuspielen,
+			"Spielstände", um deine Spielstände zu hören,
+			"App beenden", um die App zu verlassen,
+			"App neu starten", um von vorne zu beginnen und deinen gesamten Fortschritt zu löschen,
+			und "Hilfe", um dieses Hilfemenü zu hören.`,
+		},
+	},
+}
+
+// ChooseResponse picks one of the responses under key in the language of the locale carried by ctx
+func ChooseResponse(ctx context.Context, key string) string {
+	if responses, ok := LocalizedResponses[locale.Language(ctx)][key]; ok && len(responses) > 0 {
+		return common.ChooseString(responses)
+	}
+	return common.ChooseString(IntentResponses[key])
+}
+
+// KeynavCompiledLanguages is the redis set of languages a project was compiled in, besides its default
+func KeynavCompiledLanguages(pubID string) string {
+	return fmt.Sprintf("%v:languages", pubID)
+}
+
+const (
+	// DemoPubIDPrefix marks the PubID of a project as last compiled in the workbench, for demos
+	DemoPubIDPrefix = "demo:"
+	// pubIDLanguageSeparator separates a PubID from the language the project was compiled in
+	pubIDLanguageSeparator = "@"
+)
+
+// LocalizedPubID returns the PubID of the project compiled in the language of the locale carried by ctx
+// Localized projects are compiled under "{pubID}@{language}"
+// When the project wasn't compiled in that language, the PubID is returned as is
+func LocalizedPubID(ctx context.Context, pubID string) string {
+	language := locale.Language(ctx)
+	ok, err := redis.Instance.SIsMember(KeynavCompiledLanguages(pubID), language).Result()
+	if err != nil {
+		fmt.Println("Error fetching project languages", err)
+		return pubID
+	}
+	if !ok {
+		return pubID
+	}
+	return pubID + pubIDLanguageSeparator + language
+}
+
+// ParsePubID splits a PubID as built by StartApp and LocalizedPubID,
+// e.g. "demo:{projectID}@es", into the project ID, whether it's a demo, and its language if localized
+func ParsePubID(pubID string) (projectID uuid.UUID, demo bool, language string, err error) {
+	demo = strings.HasPrefix(pubID, DemoPubIDPrefix)
+	id := strings.TrimPrefix(pubID, DemoPubIDPrefix)
+	if i := strings.Index(id, pubIDLanguageSeparator); i >= 0 {
+		id, language = id[:i], id[i+len(pubIDLanguageSeparator):]
+	}
+	projectID, err = uuid.FromString(id)
+	return projectID, demo, language, err
+}
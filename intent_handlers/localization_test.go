@@ -0,0 +1,33 @@
+package intentHandlers
+
+import "testing"
+
+func TestParsePubID(t *testing.T) {
+	const id = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	tests := []struct {
+		pubID    string
+		demo     bool
+		language string
+		valid    bool
+	}{
+		{id, false, "", true},
+		{"demo:" + id, true, "", true},
+		{id + "@es", false, "es", true},
+		{"demo:" + id + "@de", true, "de", true},
+		{"demo:", true, "", false},
+		{"nonsense@es", false, "es", false},
+	}
+	for _, test := range tests {
+		projectID, demo, language, err := ParsePubID(test.pubID)
+		if (err == nil) != test.valid {
+			t.Errorf("ParsePubID(%q) error = %v, want valid %v", test.pubID, err, test.valid)
+			continue
+		}
+		if demo != test.demo || language != test.language {
+			t.Errorf("ParsePubID(%q) = demo %v, language %q, want demo %v, language %q", test.pubID, demo, language, test.demo, test.language)
+		}
+		if test.valid && projectID.String() != id {
+			t.Errorf("ParsePubID(%q) project ID = %v, want %v", test.pubID, projectID, id)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package intentHandlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// ProgressTTL is how long the progress of a user in an app is kept after they last played
+var ProgressTTL = time.Hour * 24 * 365
+
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx carrying the platform's ID of the user,
+// e.g. the AoG user ID or the Alexa userId
+// An empty ID leaves ctx unchanged
+func WithUserID(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// userIDFrom returns the user ID carried by ctx, or an empty string if there's none
+func userIDFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// KeynavUserProgress is where the progress of a user within a project is saved
+func KeynavUserProgress(userID string, projectID uuid.UUID) string {
+	return fmt.Sprintf("user:%v:project:%v:progress", userID, projectID.String())
+}
+
+// SaveProgress saves the state as the progress of the user carried by ctx
+// Nothing is saved for anonymous users, demos, or outside of an app
+// While a saved progress is on offer it is left alone, so it isn't replaced by the fresh start
+func SaveProgress(ctx context.Context, state *models.MutableAIRequestState) error {
+	userID := userIDFrom(ctx)
+	if userID == "" || state.Demo || state.ProjectID == uuid.Nil {
+		return nil
+	}
+	session, err := LoadSession(state)
+	if err != nil {
+		return err
+	}
+	if session.ResumeOffered {
+		return nil
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := redis.Instance.Set(KeynavUserProgress(userID, state.ProjectID), raw, ProgressTTL).Err(); err != nil {
+		return DatabaseFailure(err)
+	}
+	return nil
+}
+
+// loadProgress fetches the saved progress of the user in the project, or nil if there's none
+func loadProgress(userID string, projectID uuid.UUID) (*models.MutableAIRequestState, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	raw, err := redis.Instance.Get(KeynavUserProgress(userID, projectID)).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, DatabaseFailure(err)
+	}
+	state := &models.MutableAIRequestState{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, StateCorrupt(err)
+	}
+	return state, nil
+}
+
+// OfferResume asks whether to continue where the user left off if they have saved progress
+// in the app that was just started. It's answered by AppResume or AppStartOver,
+// while simply playing on starts over
+func OfferResume(ctx context.Context, message *models.AIRequest) error {
+	if message.State.Demo {
+		return nil
+	}
+	saved, err := loadProgress(userIDFrom(ctx), message.State.ProjectID)
+	if err != nil || saved == nil {
+		return err
+	}
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return err
+	}
+	ctx = session.withLocale(ctx)
+	session.ResumeOffered = true
+	message.OutputSSML.Text(ChooseResponse(ctx, "app resume offer"))
+	return session.Save(&message.State)
+}
+
+// AppResume IntentHandler restores the saved progress offered when the app started
+func AppResume(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return err
+	}
+	if !session.ResumeOffered {
+		return ErrIntentNoMatch
+	}
+	ctx = session.withLocale(ctx)
+	saved, err := loadProgress(userIDFrom(ctx), message.State.ProjectID)
+	if err != nil {
+		return err
+	}
+	if saved == nil {
+		// The progress expired since it was offered
+		return ErrIntentNoMatch
+	}
+
+	session.ResumeOffered = false
+	if err := session.Save(&message.State); err != nil {
+		return err
+	}
+
+	// The conversation carries on in the current session, which holds the locale and history
+	saved.SessionID = message.State.SessionID
+	message.State = *saved
+	message.OutputSSML.Text(ChooseResponse(ctx, "app resuming"))
+	if reprompt := Reprompt(ctx, &message.State); reprompt != "" {
+		message.OutputSSML.Text(" " + reprompt)
+	}
+	return nil
+}
+
+// AppStartOver IntentHandler declines the saved progress offered when the app started,
+// and forgets it
+func AppStartOver(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return err
+	}
+	if !session.ResumeOffered {
+		return ErrIntentNoMatch
+	}
+	ctx = session.withLocale(ctx)
+
+	session.ResumeOffered = false
+	if err := session.Save(&message.State); err != nil {
+		return err
+	}
+	if userID := userIDFrom(ctx); userID != "" {
+		if err := redis.Instance.Del(KeynavUserProgress(userID, message.State.ProjectID)).Err(); err != nil {
+			return DatabaseFailure(err)
+		}
+	}
+	message.OutputSSML.Text(ChooseResponse(ctx, "app starting over"))
+	return nil
+}
@@ -0,0 +1,150 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/brahman/nlu"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// IntentNoInput is dispatched by the routes when the platform reports that the user said nothing
+const IntentNoInput = "talkative.no_input"
+
+// MaxNoInputs is how many silent turns in a row end the conversation
+var MaxNoInputs = 3
+
+// KeynavCompiledDialogReprompt is where the reprompt an author wrote for a dialog node lives
+func KeynavCompiledDialogReprompt(pubID, dialogID string) string {
+	return fmt.Sprintf("%v:dialog:%v:reprompt", pubID, dialogID)
+}
+
+// Reprompt is what to say to nudge a player who hasn't answered
+// It's the reprompt compiled for the current dialog node if the author wrote one,
+// otherwise a suggestion made from the examples of what can be said next
+// It's empty outside of an app, or when there's nothing to suggest
+func Reprompt(ctx context.Context, state *models.MutableAIRequestState) string {
+	if state.PubID == "" {
+		return ""
+	}
+	if state.CurrentDialog != nil {
+		reprompt, err := redis.Instance.Get(KeynavCompiledDialogReprompt(state.PubID, DialogIDFromKey(*state.CurrentDialog))).Result()
+		if err != nil && err != goredis.Nil {
+			fmt.Println("Error fetching reprompt", err)
+		}
+		if reprompt != "" {
+			return reprompt
+		}
+	}
+
+	examples := nextExamples(state, maxChoices)
+	if len(examples) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(ChooseResponse(ctx, "you could say"), listPhrases(examples))
+}
+
+// NoInputReprompt is the reprompt for platforms which handle silence themselves, such as Alexa
+func NoInputReprompt(ctx context.Context, state *models.MutableAIRequestState) string {
+	reprompt := ChooseResponse(ctx, "no input")
+	if next := Reprompt(ctx, state); next != "" {
+		reprompt = fmt.Sprintf("%v %v", reprompt, next)
+	}
+	return reprompt
+}
+
+// NoInput handles a turn where the user said nothing
+// Messages escalate with every silent turn in a row, and after MaxNoInputs the conversation ends
+// It reports whether the conversation should end
+func NoInput(ctx context.Context, message *models.AIRequest) (bool, error) {
+	if message.State.SessionID == uuid.Nil {
+		// Outside of an app there's no session yet, but the count must survive to the next turn
+		message.State.SessionID = uuid.NewV4()
+	}
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return false, err
+	}
+	ctx = session.withLocale(ctx)
+
+	session.NoInputs++
+	if session.NoInputs >= MaxNoInputs {
+		session.NoInputs = 0
+		message.OutputSSML.Text(ChooseResponse(ctx, "no input end"))
+		return true, session.Save(&message.State)
+	}
+
+	if session.NoInputs == 1 {
+		message.OutputSSML.Text(ChooseResponse(ctx, "no input"))
+	} else {
+		message.OutputSSML.Text(ChooseResponse(ctx, "no input again"))
+	}
+	if reprompt := Reprompt(ctx, &message.State); reprompt != "" {
+		message.OutputSSML.Text(" " + reprompt)
+	}
+	return false, session.Save(&message.State)
+}
+
+// resetNoInputs ends the silence counted by NoInput
+// It's called on every turn where the user said something, in or out of an app,
+// whichever handler the turn ends up with
+func resetNoInputs(state *models.MutableAIRequestState) error {
+	if state.SessionID == uuid.Nil {
+		return nil
+	}
+	session, err := LoadSession(state)
+	if err != nil || session.NoInputs == 0 {
+		return err
+	}
+	session.NoInputs = 0
+	return session.Save(state)
+}
+
+// nextExamples returns up to n example phrases the player could say next,
+// taken from the children of the current dialog node, or else from the root dialogs of the zone
+func nextExamples(state *models.MutableAIRequestState, n int) []string {
+	var contexts []string
+	if state.CurrentDialog != nil {
+		contexts = append(contexts, models.KeynavCompiledDialogNode(state.PubID, DialogIDFromKey(*state.CurrentDialog)))
+	} else {
+		for _, actorID := range state.ZoneActors[state.Zone] {
+			contexts = append(contexts, models.KeynavCompiledDialogRootWithinActor(state.PubID, actorID))
+		}
+	}
+
+	examples := []string{}
+	for _, key := range contexts {
+		dataset, err := nlu.LoadDataset(key)
+		if err != nil {
+			fmt.Println("Error loading dataset", err)
+			continue
+		}
+		// Sorted so the same examples are suggested every time
+		names := make([]string, 0, len(dataset.Intents))
+		for name := range dataset.Intents {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if len(examples) == n {
+				return examples
+			}
+			if example := dataset.Intents[name].Example(); example != "" {
+				examples = append(examples, example)
+			}
+		}
+	}
+	return examples
+}
+
+// Suggestions returns phrases the player could say next, for clients able to offer them as replies
+func Suggestions(state *models.MutableAIRequestState) []string {
+	if state.ProjectID == uuid.Nil {
+		return []string{}
+	}
+	return nextExamples(state, maxChoices)
+}
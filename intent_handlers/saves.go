@@ -0,0 +1,178 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/talkative-ai/brahman/saves"
+	"github.com/talkative-ai/core/models"
+	uuid "github.com/talkative-ai/go.uuid"
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// MaxSaves is how many named saves a user may keep per app
+var MaxSaves = 10
+
+// saveSlotName is the NLU slot holding the name of a save, e.g. "castle run"
+const saveSlotName = "saveName"
+
+// saveName returns the normalized save name from the NLU result, or an empty string
+func saveName(input *snips.Result) string {
+	if input == nil {
+		return ""
+	}
+	return saves.NormalizeName(input.SlotsMappedByName()[saveSlotName].RawValue)
+}
+
+// savesAvailable reports whether the player can use named saves,
+// which requires being within a published app as a known user
+func savesAvailable(ctx context.Context, state *models.MutableAIRequestState) bool {
+	return userIDFrom(ctx) != "" && !state.Demo && state.ProjectID != uuid.Nil
+}
+
+// AppSave IntentHandler saves the progress under a name, e.g. "save game as castle run"
+func AppSave(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	if message.State.ProjectID == uuid.Nil {
+		return ErrIntentNoMatch
+	}
+	if !savesAvailable(ctx, &message.State) {
+		message.OutputSSML.Text(ChooseResponse(ctx, "saves unavailable"))
+		return nil
+	}
+	name := saveName(input)
+	if name == "" {
+		message.OutputSSML.Text(ChooseResponse(ctx, "save name missing"))
+		return nil
+	}
+
+	userID := userIDFrom(ctx)
+	existing, err := saves.Instance.List(userID, message.State.ProjectID)
+	if err != nil {
+		return DatabaseFailure(err)
+	}
+	if len(existing) >= MaxSaves && !hasSave(existing, name) {
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "saves full"), MaxSaves))
+		return nil
+	}
+
+	err = saves.Instance.Put(userID, message.State.ProjectID, &saves.Save{
+		Name:    name,
+		State:   message.State,
+		SavedAt: time.Now(),
+	})
+	if err != nil {
+		return DatabaseFailure(err)
+	}
+	message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "game saved"), name))
+	return nil
+}
+
+// AppLoad IntentHandler restores the progress saved under a name, e.g. "load castle run"
+// The loaded state continues within the current session, so going back doesn't leave the save
+func AppLoad(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	if message.State.ProjectID == uuid.Nil {
+		return ErrIntentNoMatch
+	}
+	if !savesAvailable(ctx, &message.State) {
+		message.OutputSSML.Text(ChooseResponse(ctx, "saves unavailable"))
+		return nil
+	}
+	name := saveName(input)
+	if name == "" {
+		return AppListSaves(ctx, input, message)
+	}
+
+	save, err := saves.Instance.Get(userIDFrom(ctx), message.State.ProjectID, name)
+	if err == saves.ErrNotFound {
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "save not found"), name))
+		return nil
+	}
+	if err != nil {
+		return DatabaseFailure(err)
+	}
+
+	session, err := LoadSession(&message.State)
+	if err != nil {
+		return err
+	}
+	session.History = nil
+	session.Choices = nil
+	session.ResumeOffered = false
+
+	sessionID := message.State.SessionID
+	message.State = save.State
+	message.State.SessionID = sessionID
+	if err := session.Save(&message.State); err != nil {
+		return err
+	}
+
+	message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "save loaded"), name))
+	if reprompt := Reprompt(ctx, &message.State); reprompt != "" {
+		message.OutputSSML.Text(" " + reprompt)
+	}
+	return nil
+}
+
+// AppListSaves IntentHandler lists the names of the saves, most recent first
+func AppListSaves(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	if message.State.ProjectID == uuid.Nil {
+		return ErrIntentNoMatch
+	}
+	if !savesAvailable(ctx, &message.State) {
+		message.OutputSSML.Text(ChooseResponse(ctx, "saves unavailable"))
+		return nil
+	}
+
+	list, err := saves.Instance.List(userIDFrom(ctx), message.State.ProjectID)
+	if err != nil {
+		return DatabaseFailure(err)
+	}
+	if len(list) == 0 {
+		message.OutputSSML.Text(ChooseResponse(ctx, "no saves"))
+		return nil
+	}
+	names := make([]string, len(list))
+	for i, save := range list {
+		names[i] = save.Name
+	}
+	message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "saves list"), listPhrases(names)))
+	return nil
+}
+
+// AppDeleteSave IntentHandler deletes the save with the given name, e.g. "delete castle run"
+func AppDeleteSave(ctx context.Context, input *snips.Result, message *models.AIRequest) error {
+	if message.State.ProjectID == uuid.Nil {
+		return ErrIntentNoMatch
+	}
+	if !savesAvailable(ctx, &message.State) {
+		message.OutputSSML.Text(ChooseResponse(ctx, "saves unavailable"))
+		return nil
+	}
+	name := saveName(input)
+	if name == "" {
+		message.OutputSSML.Text(ChooseResponse(ctx, "save name missing"))
+		return nil
+	}
+
+	err := saves.Instance.Delete(userIDFrom(ctx), message.State.ProjectID, name)
+	if err == saves.ErrNotFound {
+		message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "save not found"), name))
+		return nil
+	}
+	if err != nil {
+		return DatabaseFailure(err)
+	}
+	message.OutputSSML.Text(fmt.Sprintf(ChooseResponse(ctx, "save deleted"), name))
+	return nil
+}
+
+// hasSave reports whether a save of the given name is among the list
+func hasSave(list []saves.Save, name string) bool {
+	for _, save := range list {
+		if save.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/brahman/nlu"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/redis"
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// candidate is a dialog matched by the NLU within a compiled context
+type candidate struct {
+	Context string
+	Result  snips.Result
+	// Global is set for the author's app-wide commands
+	Global bool
+}
+
+// KeynavCompiledDialogGlobal is the compiled context of the dialogs available anywhere in an app,
+// whatever the current dialog is
+func KeynavCompiledDialogGlobal(pubID string) string {
+	return fmt.Sprintf("%v:dialog:global", pubID)
+}
+
+// maxCandidatesPerContext is how many ranked intents are requested from each context
+const maxCandidatesPerContext = maxChoices
+
+// selection is the dialog an input leads to within an app
+type selection struct {
+	// DialogKey is empty when nothing matched, not even an unknown handler
+	DialogKey string
+	// Choices are set instead of DialogKey when several dialogs are about as likely
+	Choices []DialogChoice
+	// Global is set when the dialog is one of the author's app-wide commands
+	Global bool
+	// CatchAll is set when nothing matched and the dialog is the catch-all unknown handler
+	CatchAll bool
+}
+
+// selectDialog decides which dialog the input leads to from the given state
+// Pending choices of the session are resolved first, then the dialog node children
+// or the actor root dialogs are matched, and finally the unknown handlers are tried
+// When explain is not nil every step is recorded into it
+func selectDialog(ctx context.Context, rawInput string, state *models.MutableAIRequestState, session *Session, explain *Explanation) (*selection, error) {
+	pubID := state.PubID
+	selected := &selection{}
+
+	if choice := session.resolveChoice(rawInput); choice != nil {
+		// The user answered a "did you mean" prompt from the previous turn
+		selected.DialogKey = choice.DialogKey
+		selected.Global = choice.Global
+		if explain != nil {
+			explain.ResolvedChoice = choice
+			explain.Winner = choice.DialogKey
+		}
+		return selected, nil
+	}
+
+	var contexts, sources []string
+	if state.CurrentDialog != nil {
+		// Attempt to fetch a dialog relative to the current dialog.
+		// Otherwise known as dialog node children
+		// This is where conversational context works
+		currentDialogID := DialogIDFromKey(*state.CurrentDialog)
+		contexts = append(contexts, models.KeynavCompiledDialogNode(pubID, currentDialogID))
+		sources = append(sources, fmt.Sprintf("dialog %v", currentDialogID))
+	} else {
+		// If there is no current dialog, then we scan all "root dialogs"
+		// for the actors within the Zone
+		// This is where conversations begin
+		for _, actorID := range state.ZoneActors[state.Zone] {
+			contexts = append(contexts, models.KeynavCompiledDialogRootWithinActor(pubID, actorID))
+			sources = append(sources, fmt.Sprintf("actor %v", actorID))
+		}
+	}
+
+	threshold := loadThresholds(pubID)
+	candidates, err := matchPassing(ctx, contexts, sources, rawInput, state, threshold, explain)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		// Nothing matched where the conversation is, so try the commands
+		// the author made available everywhere in the app, e.g. "inventory"
+		global := []string{KeynavCompiledDialogGlobal(pubID)}
+		candidates, err = matchPassing(ctx, global, []string{"global"}, rawInput, state, threshold, explain)
+		if err != nil {
+			return nil, err
+		}
+		for i := range candidates {
+			candidates[i].Global = true
+		}
+	}
+
+	if choices := closeChoices(candidates); len(choices) > 1 {
+		selected.Choices = choices
+		if explain != nil {
+			explain.Choices = choices
+		}
+		return selected, nil
+	}
+
+	if len(candidates) > 0 {
+		selected.DialogKey = candidates[0].Result.Intent.Name
+		selected.Global = candidates[0].Global
+	} else {
+		// There were no dialogs at all with the given input
+		// So we check to see if there's a "catch-all" unknown dialog handler
+		selected.DialogKey = unknownDialog(state, explain)
+		selected.CatchAll = selected.DialogKey != ""
+	}
+
+	if explain != nil {
+		explain.Winner = selected.DialogKey
+		explain.CatchAll = selected.CatchAll
+	}
+	return selected, nil
+}
+
+// matchPassing matches the contexts and keeps the candidates reaching their threshold
+func matchPassing(ctx context.Context, contexts, sources []string, rawInput string, state *models.MutableAIRequestState, threshold thresholds, explain *Explanation) ([]candidate, error) {
+	candidates, err := matchCandidates(ctx, contexts, rawInput)
+	if err != nil {
+		return nil, err
+	}
+	if explain != nil {
+		explain.recordCandidates(contexts, sources, candidates, threshold, state)
+	}
+	candidates = threshold.filter(state.Zone, candidates)
+	return candidates, nil
+}
+
+// unknownDialog finds the catch-all dialog of the current dialog node, or of the actors in the zone
+func unknownDialog(state *models.MutableAIRequestState, explain *Explanation) string {
+	var keys []string
+	if state.CurrentDialog != nil {
+		keys = append(keys, models.KeynavCompiledDialogNodeUnknown(state.PubID, DialogIDFromKey(*state.CurrentDialog)))
+	} else {
+		for _, actorID := range state.ZoneActors[state.Zone] {
+			keys = append(keys, models.KeynavCompiledDialogRootUnknownWithinActor(state.PubID, actorID))
+		}
+	}
+
+	for _, key := range keys {
+		v := redis.Instance.Get(key)
+		if explain != nil {
+			explain.Unknown = append(explain.Unknown, UnknownExplanation{Context: key, Dialog: v.Val()})
+		}
+		if v.Err() != nil && v.Err() != goredis.Nil {
+			fmt.Println("Error fetching unknown dialog", v.Err())
+			continue
+		}
+		// Actors without a catch-all leave it to the others in the zone
+		if v.Val() != "" {
+			return v.Val()
+		}
+	}
+	return ""
+}
+
+// matchCandidates queries every context concurrently and merges the results, best first
+// Ties are broken by the lowest dialog key so that the outcome
+// does not depend on the order of the contexts, e.g. the actors in a zone
+func matchCandidates(ctx context.Context, contexts []string, rawInput string) ([]candidate, error) {
+	input := models.DialogInput(rawInput).Prepared()
+	results := make([][]snips.Result, len(contexts))
+	errs := make([]error, len(contexts))
+
+	var wg sync.WaitGroup
+	for i, key := range contexts {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			results[i], errs[i] = nlu.MatchN(ctx, nlu.Matcher, key, input, maxCandidatesPerContext)
+		}(i, key)
+	}
+	wg.Wait()
+
+	candidates := []candidate{}
+	for i, contextResults := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for _, result := range contextResults {
+			if result.Intent.Name == "" {
+				continue
+			}
+			candidates = append(candidates, candidate{Context: contexts[i], Result: result})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].Result.Intent, candidates[j].Result.Intent
+		if a.Probability != b.Probability {
+			return a.Probability > b.Probability
+		}
+		return a.Name < b.Name
+	})
+	return candidates, nil
+}
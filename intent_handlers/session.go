@@ -0,0 +1,85 @@
+package intentHandlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// SessionTTL is how long Brahman keeps session state after the last turn
+var SessionTTL = time.Hour * 720
+
+// Session is conversation state Brahman keeps alongside models.MutableAIRequestState
+// It lives in redis under the SessionID of the state, so every platform shares it
+// regardless of how the platform carries the state itself
+type Session struct {
+	// Choices are the dialogs offered by a pending "did you mean" prompt
+	Choices []DialogChoice `json:",omitempty"`
+	// Locale is the last locale the platform gave, e.g. "de-DE"
+	Locale string `json:",omitempty"`
+	// NoInputs counts the turns in a row where the user said nothing
+	NoInputs int `json:",omitempty"`
+	// ResumeOffered is set while the user is asked whether to continue their saved progress
+	ResumeOffered bool `json:",omitempty"`
+	// Misses counts the turns in a row where the input wasn't understood
+	Misses int `json:",omitempty"`
+	// History are snapshots of the state before each dialog ran, oldest first
+	History []models.MutableAIRequestState `json:",omitempty"`
+}
+
+// KeynavSession is where the Brahman session state of a conversation lives
+func KeynavSession(sessionID uuid.UUID) string {
+	return fmt.Sprintf("session:%v:brahman", sessionID.String())
+}
+
+// LoadSession fetches the session belonging to the state
+// Conversations outside of an app have no SessionID, and get a fresh session every turn
+func LoadSession(state *models.MutableAIRequestState) (*Session, error) {
+	session := &Session{}
+	if state.SessionID == uuid.Nil {
+		return session, nil
+	}
+	raw, err := redis.Instance.Get(KeynavSession(state.SessionID)).Bytes()
+	if err == goredis.Nil {
+		return session, nil
+	}
+	if err != nil {
+		return nil, DatabaseFailure(err)
+	}
+	if err := json.Unmarshal(raw, session); err != nil {
+		return nil, StateCorrupt(err)
+	}
+	return session, nil
+}
+
+// Save stores the session for the state's SessionID
+func (s *Session) Save(state *models.MutableAIRequestState) error {
+	if state.SessionID == uuid.Nil {
+		return nil
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := redis.Instance.Set(KeynavSession(state.SessionID), raw, SessionTTL).Err(); err != nil {
+		return DatabaseFailure(err)
+	}
+	return nil
+}
+
+// withLocale keeps the locale carried by ctx with the session,
+// or carries the session's locale in ctx when the platform didn't give one
+func (s *Session) withLocale(ctx context.Context) context.Context {
+	if current := locale.From(ctx); current != "" {
+		s.Locale = current
+		return ctx
+	}
+	return locale.With(ctx, s.Locale)
+}
@@ -0,0 +1,34 @@
+package intentHandlers
+
+import (
+	"context"
+	"strings"
+)
+
+// OutputListener is told the SSML each action bundle outputs, as it's evaluated
+// The SSML is a fragment, without the enclosing speak element
+type OutputListener func(raw string)
+
+type outputListenerKey struct{}
+
+// WithOutputListener returns a copy of ctx which tells listener about the output as the dialog logic produces it,
+// so it can be delivered progressively rather than all at once when the turn ends
+// The listener is called from within the evaluation, whose time is limited, so it shouldn't block
+func WithOutputListener(ctx context.Context, listener OutputListener) context.Context {
+	return context.WithValue(ctx, outputListenerKey{}, listener)
+}
+
+// outputListenerFrom returns the listener carried by ctx, or nil when there's none
+func outputListenerFrom(ctx context.Context) OutputListener {
+	listener, _ := ctx.Value(outputListenerKey{}).(OutputListener)
+	return listener
+}
+
+// outputAdded returns the SSML appended to the output since it was before
+// Output which was replaced rather than appended to is returned whole
+func outputAdded(before, after string) string {
+	if strings.HasPrefix(after, before) {
+		return after[len(before):]
+	}
+	return after
+}
@@ -0,0 +1,68 @@
+package intentHandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// DefaultProbabilityThreshold is the minimum intent probability required to trigger a dialog
+// when the project doesn't configure one. It is configurable on startup
+var DefaultProbabilityThreshold = 0.8
+
+// KeynavCompiledThresholds is the redis hash where the compiled probability thresholds of a project live
+// Fields are "project", "zone:{zoneID}" and "dialog:{dialogID}"
+func KeynavCompiledThresholds(pubID string) string {
+	return fmt.Sprintf("%v:thresholds", pubID)
+}
+
+// thresholds holds the compiled probability thresholds of a project
+type thresholds map[string]string
+
+// loadThresholds fetches the compiled thresholds of a project
+// Failure to fetch is not fatal, the default threshold is used instead
+func loadThresholds(pubID string) thresholds {
+	values, err := redis.Instance.HGetAll(KeynavCompiledThresholds(pubID)).Result()
+	if err != nil {
+		fmt.Println("Error fetching thresholds", err)
+		return thresholds{}
+	}
+	return thresholds(values)
+}
+
+// For returns the threshold a dialog must reach to be triggered
+// A dialog override takes precedence over the zone, which takes precedence over the project
+func (t thresholds) For(zoneID uuid.UUID, dialogKey string) float64 {
+	for _, field := range []string{
+		fmt.Sprintf("dialog:%v", DialogIDFromKey(dialogKey)),
+		fmt.Sprintf("zone:%v", zoneID.String()),
+		"project",
+	} {
+		if raw, ok := t[field]; ok {
+			if value, err := strconv.ParseFloat(raw, 64); err == nil {
+				return value
+			}
+		}
+	}
+	return DefaultProbabilityThreshold
+}
+
+// filter drops the candidates which don't reach their threshold
+func (t thresholds) filter(zoneID uuid.UUID, candidates []candidate) []candidate {
+	passed := []candidate{}
+	for _, c := range candidates {
+		if c.Result.Intent.Probability > t.For(zoneID, c.Result.Intent.Name) {
+			passed = append(passed, c)
+		}
+	}
+	return passed
+}
+
+// DialogIDFromKey extracts the dialog ID from a compiled dialog key, so either may be given
+func DialogIDFromKey(dialogKey string) string {
+	split := strings.Split(dialogKey, ":")
+	return split[len(split)-1]
+}
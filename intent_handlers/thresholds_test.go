@@ -0,0 +1,46 @@
+package intentHandlers
+
+import (
+	"testing"
+
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+func TestThresholdsFor(t *testing.T) {
+	zoneID := uuid.NewV4()
+	otherZoneID := uuid.NewV4()
+	dialogKey := "pub:dialog:abc"
+
+	tests := []struct {
+		name       string
+		thresholds thresholds
+		zoneID     uuid.UUID
+		want       float64
+	}{
+		{"default", thresholds{}, zoneID, DefaultProbabilityThreshold},
+		{"project", thresholds{"project": "0.5"}, zoneID, 0.5},
+		{"zone over project", thresholds{
+			"project":                      "0.5",
+			"zone:" + zoneID.String():      "0.6",
+			"zone:" + otherZoneID.String(): "0.9",
+		}, zoneID, 0.6},
+		{"dialog over zone", thresholds{
+			"project":                 "0.5",
+			"zone:" + zoneID.String(): "0.6",
+			"dialog:abc":              "0.7",
+		}, zoneID, 0.7},
+		{"other zone", thresholds{
+			"project":                 "0.5",
+			"zone:" + zoneID.String(): "0.6",
+		}, otherZoneID, 0.5},
+		{"malformed is skipped", thresholds{
+			"project":    "0.5",
+			"dialog:abc": "high",
+		}, zoneID, 0.5},
+	}
+	for _, test := range tests {
+		if got := test.thresholds.For(test.zoneID, dialogKey); got != test.want {
+			t.Errorf("%v: For() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
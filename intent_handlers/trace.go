@@ -0,0 +1,126 @@
+package intentHandlers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/talkative-ai/core/models"
+)
+
+// Trace records how a turn within an app was executed, so authors can debug their logic
+// Tracing is enabled by carrying a Trace in the context with WithTrace
+// A nil Trace records nothing, so a turn without one does no extra work
+type Trace struct {
+	// Selection is how the dialog was chosen, including every context queried
+	Selection *Explanation `json:",omitempty"`
+	// Dialog is the dialog which ran, empty when none did
+	Dialog string
+	// Steps are the values yielded by the dialog logic, in order of evaluation
+	// The branches the logic evaluated to reach them aren't included:
+	// LogicLazyEval in core only yields action bundle keys, and has no hook to report its branches
+	Steps []TraceStep
+	// Limit is the execution limit which ended the turn early, if any
+	Limit string `json:",omitempty"`
+}
+
+// TraceStep is an action bundle the dialog logic led to
+type TraceStep struct {
+	// Bundle is the key of the action bundle
+	// It's empty when the logic itself failed
+	Bundle string
+	// Changes are the state fields the bundle changed
+	Changes []StateChange `json:",omitempty"`
+	Error   string        `json:",omitempty"`
+}
+
+// StateChange is a field of models.MutableAIRequestState changed by an action bundle
+type StateChange struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+type traceKey struct{}
+
+// WithTrace returns a copy of ctx which records the turn into the trace
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// traceFrom returns the trace carried by ctx, or nil when tracing is disabled
+func traceFrom(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// explanation starts recording the selection of the dialog for the input
+func (t *Trace) explanation(rawInput string) *Explanation {
+	if t == nil {
+		return nil
+	}
+	t.Selection = &Explanation{
+		Input:    rawInput,
+		Contexts: []ContextExplanation{},
+	}
+	return t.Selection
+}
+
+// snapshot captures the fields of the state to compare after a bundle is evaluated
+func (t *Trace) snapshot(state models.MutableAIRequestState) map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	raw, err := json.Marshal(state)
+	if err == nil {
+		json.Unmarshal(raw, &fields)
+	}
+	return fields
+}
+
+// recordBundle records the evaluation of a bundle, and how it changed the state since the snapshot
+func (t *Trace) recordBundle(bundle string, before map[string]interface{}, state models.MutableAIRequestState, err error) {
+	if t == nil {
+		return
+	}
+	step := TraceStep{
+		Bundle:  bundle,
+		Changes: diffFields(before, t.snapshot(state)),
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	t.Steps = append(t.Steps, step)
+}
+
+// recordLogicError records that the dialog logic failed to evaluate
+func (t *Trace) recordLogicError(err error) {
+	if t == nil {
+		return
+	}
+	t.Steps = append(t.Steps, TraceStep{Error: err.Error()})
+}
+
+// diffFields lists the fields which differ between the snapshots, sorted by name
+func diffFields(before, after map[string]interface{}) []StateChange {
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	changes := []StateChange{}
+	for name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, StateChange{Field: name, Before: before[name], After: after[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
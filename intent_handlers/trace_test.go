@@ -0,0 +1,36 @@
+package intentHandlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]interface{}
+		want          []StateChange
+	}{
+		{"nothing", nil, nil, []StateChange{}},
+		{"unchanged", map[string]interface{}{"Zone": "a"}, map[string]interface{}{"Zone": "a"}, []StateChange{}},
+		{"changed", map[string]interface{}{"Zone": "a"}, map[string]interface{}{"Zone": "b"}, []StateChange{
+			{Field: "Zone", Before: "a", After: "b"},
+		}},
+		{"added and removed, sorted", map[string]interface{}{"Zone": "a", "CurrentDialog": "x"}, map[string]interface{}{"Zone": "a", "Demo": true}, []StateChange{
+			{Field: "CurrentDialog", Before: "x", After: nil},
+			{Field: "Demo", Before: nil, After: true},
+		}},
+		{"nested", map[string]interface{}{
+			"ZoneInitialized": map[string]interface{}{"a": true},
+		}, map[string]interface{}{
+			"ZoneInitialized": map[string]interface{}{"a": true, "b": true},
+		}, []StateChange{
+			{Field: "ZoneInitialized", Before: map[string]interface{}{"a": true}, After: map[string]interface{}{"a": true, "b": true}},
+		}},
+	}
+	for _, test := range tests {
+		if got := diffFields(test.before, test.after); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: diffFields() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package intentHandlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/talkative-ai/brahman/nlu"
+	"github.com/talkative-ai/core/models"
+	uuid "github.com/talkative-ai/go.uuid"
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// IntentRepeat asks to hear the last response of the app again
+const IntentRepeat = "repeat"
+
+// IntentStop asks to leave the app
+const IntentStop = "app.stop"
+
+// Turn is a single exchange with the user, whichever platform it came through
+// Platform adapters translate their request into a Turn, Run it,
+// render the output, then Finish it to keep what was said for next time
+type Turn struct {
+	// Input is what the user said
+	Input string
+	// Intent is set when the platform recognized the intent itself, e.g. Alexa's built-in intents
+	// The NLU isn't used then
+	Intent string
+	// NoInput is set when the platform reports that the user said nothing
+	NoInput bool
+	// New is set on the first turn of a conversation
+	New bool
+	// ProjectID is the app new conversations start in, for platforms bound to a single app
+	// Otherwise new conversations start with Talkative
+	ProjectID uuid.UUID
+	// Demo starts the app as a demo
+	Demo bool
+	// StopEnds makes stopping the app end the conversation rather than return to Talkative,
+	// for platforms where the app is all there is
+	StopEnds bool
+
+	// Request holds the state carried over from the previous turn, and receives the output
+	Request *models.AIRequest
+
+	// Parsed is the intent the input was recognized as
+	Parsed snips.Result
+	// Repeat is set when the platform should replay State.PreviousResponse rather than the output
+	Repeat bool
+	// End is set when the conversation is over
+	End bool
+	// AppResponded is set when the output came from the app, which is what "repeat" replays
+	AppResponded bool
+}
+
+// Run handles the turn, leaving the output in Request
+func (t *Turn) Run(ctx context.Context) error {
+	if t.New {
+		if t.ProjectID != uuid.Nil {
+			StartApp(ctx, t.Request, t.ProjectID, t.Demo)
+			t.AppResponded = true
+			return nil
+		}
+		t.Parsed.Intent.Name = "talkative.welcome"
+	} else {
+		t.parse(ctx)
+	}
+	if t.Parsed.Intent.Name != IntentNoInput {
+		if err := resetNoInputs(&t.Request.State); err != nil {
+			return err
+		}
+	}
+
+	handled, err := t.dispatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	inApp := t.Request.State.ProjectID != uuid.Nil
+	if !handled && inApp {
+		err = InAppHandler(ctx, t.Input, t.Request)
+		if err == nil {
+			handled = true
+			t.AppResponded = true
+		} else if nlu.IsUnavailable(err) {
+			fmt.Println("Error", err)
+			handled = true
+			err = Unavailable(ctx, &t.Parsed, t.Request)
+		} else if err == ErrIntentNoMatch {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !handled {
+		return Unknown(ctx, &t.Parsed, t.Request)
+	}
+	return nil
+}
+
+// parse recognizes the intent of the input among the system intents
+func (t *Turn) parse(ctx context.Context) {
+	if t.NoInput {
+		t.Parsed.Intent.Name = IntentNoInput
+		return
+	}
+	if t.Intent != "" {
+		t.Parsed.Intent.Name = t.Intent
+		t.Parsed.Intent.Probability = 1
+		return
+	}
+
+	// Within an app the system intents are those of the app, e.g. "stop app",
+	// otherwise the conversation is with Talkative itself
+	context := models.KeynavStaticIntentsTalkative()
+	if t.Request.State.ProjectID != uuid.Nil {
+		context = models.KeynavStaticIntentsApp()
+	}
+	result, err := nlu.Matcher.Match(ctx, context, models.DialogInput(t.Input).Prepared())
+	if err != nil {
+		fmt.Println("Error", err)
+		t.Parsed.Intent.Name = IntentUnavailable
+		return
+	}
+	t.Parsed = *result
+}
+
+// dispatch handles the system intents, reporting whether the intent was handled
+func (t *Turn) dispatch(ctx context.Context) (bool, error) {
+	var err error
+	inApp := t.Request.State.ProjectID != uuid.Nil
+
+	switch name := t.Parsed.Intent.Name; {
+	case name == IntentRepeat:
+		t.Repeat = true
+		return true, nil
+	case (name == IntentBack || name == "AMAZON.PreviousIntent") && inApp:
+		// Going back restores an earlier state, then replays what was said there
+		t.Repeat, err = GoBack(ctx, t.Request)
+		return true, err
+	case name == IntentStop && t.StopEnds:
+		t.End = true
+		return true, nil
+	case name == IntentNoInput:
+		t.End, err = NoInput(ctx, t.Request)
+		return true, err
+	}
+
+	handler, ok := List[t.Parsed.Intent.Name]
+	if !ok {
+		return false, nil
+	}
+	err = handler(ctx, &t.Parsed, t.Request)
+	if err == ErrIntentNoMatch {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if t.Parsed.Intent.Name == "talkative.app.initialize" {
+		t.AppResponded = true
+	}
+	return true, nil
+}
+
+// Finish keeps the platform's rendering of the response for "repeat", if it came from the app,
+// and saves the progress of the user
+func (t *Turn) Finish(ctx context.Context, previousResponse string) {
+	if t.AppResponded && !t.Repeat {
+		t.Request.State.PreviousResponse = previousResponse
+	}
+	if err := SaveProgress(ctx, &t.Request.State); err != nil {
+		// The turn still succeeds, only the progress wasn't saved
+		fmt.Println("Error saving progress", err)
+	}
+}
@@ -0,0 +1,36 @@
+package locale
+
+import (
+	"context"
+	"strings"
+)
+
+// Default is the locale assumed when the platform doesn't provide one
+const Default = "en-US"
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying the locale, e.g. "es-ES"
+// An empty locale leaves ctx unchanged
+func With(ctx context.Context, locale string) context.Context {
+	if locale == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// From returns the locale carried by ctx, or an empty string if there's none
+func From(ctx context.Context) string {
+	locale, _ := ctx.Value(contextKey{}).(string)
+	return locale
+}
+
+// Language returns the lowercase language of the locale carried by ctx, e.g. "es" for "es-ES"
+// It falls back to the language of Default
+func Language(ctx context.Context) string {
+	locale := From(ctx)
+	if locale == "" {
+		locale = Default
+	}
+	return strings.ToLower(strings.Split(strings.Replace(locale, "_", "-", -1), "-")[0])
+}
@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/rs/cors"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/nlu"
 	"github.com/talkative-ai/brahman/routes"
+	"github.com/talkative-ai/brahman/saves"
 	"github.com/talkative-ai/core/db"
 	"github.com/talkative-ai/core/redis"
 	"github.com/talkative-ai/core/router"
@@ -41,6 +47,48 @@ func main() {
 	}
 	defer redis.Instance.Close()
 
+	nlu.Matcher = nlu.NewFallback(nlu.NewKalidasa(os.Getenv("KALIDASA_ADDR")), &nlu.Offline{})
+
+	if threshold := os.Getenv("NLU_THRESHOLD"); threshold != "" {
+		intentHandlers.DefaultProbabilityThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if err := parseTurnLimits(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if ttl := os.Getenv("CHAT_SESSION_TTL"); ttl != "" {
+		routes.ChatSessionTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	routes.GoogleProjectID = os.Getenv("GOOGLE_PROJECT_ID")
+	routes.DialogflowAuth = os.Getenv("DIALOGFLOW_AUTH")
+
+	if os.Getenv("SAVE_STORE") == "postgres" {
+		saves.Instance = &saves.Postgres{}
+	}
+
+	// Any arguments run a command rather than the server
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+		if err := command(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 	})
@@ -50,6 +98,12 @@ func main() {
 	router.ApplyRoute(r, routes.PostDemo)
 	router.ApplyRoute(r, routes.PostGoogleAuth)
 	router.ApplyRoute(r, routes.PostGoogleAuthToken)
+	router.ApplyRoute(r, routes.PostExplain)
+	router.ApplyRoute(r, routes.PostDialogflow)
+	router.ApplyRoute(r, routes.PostActions)
+	router.ApplyRoute(r, routes.PostChatSession)
+	router.ApplyRoute(r, routes.PostChatMessage)
+	router.ApplyRoute(r, routes.GetConversation)
 
 	skillserver.SetEchoPrefix("/ai/v1/alexa/")
 	skillserver.Init(map[string]interface{}{
@@ -58,8 +112,11 @@ func main() {
 		},
 	}, r)
 
+	allowedOrigins := []string{"https://talkative.ai", "https://harihara.ngrok.io", "http://brahman.ngrok.io", "https://brahman.ngrok.io", "https://workbench.talkative.ai", "http://localhost:3000", "http://localhost:8080", "http://localhost:3001"}
+	routes.WebSocketOrigins = allowedOrigins
+
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"https://talkative.ai", "https://harihara.ngrok.io", "http://brahman.ngrok.io", "https://brahman.ngrok.io", "https://workbench.talkative.ai", "http://localhost:3000", "http://localhost:8080", "http://localhost:3001"},
+		AllowedOrigins:   allowedOrigins,
 		AllowCredentials: true,
 		AllowedHeaders:   []string{"x-token", "accept", "content-type"},
 		ExposedHeaders:   []string{"etag", "x-token"},
@@ -72,3 +129,40 @@ func main() {
 
 	log.Fatal(http.ListenAndServe(":8080", wrapRequest(http.DefaultServeMux)))
 }
+
+// parseTurnLimits overrides the default execution limits of dialog logic from the environment
+func parseTurnLimits() error {
+	if maxBundles := os.Getenv("MAX_BUNDLES_PER_TURN"); maxBundles != "" {
+		value, err := strconv.Atoi(maxBundles)
+		if err != nil {
+			return err
+		}
+		intentHandlers.TurnLimits.MaxBundles = value
+	}
+	if maxOutput := os.Getenv("MAX_OUTPUT_LENGTH"); maxOutput != "" {
+		value, err := strconv.Atoi(maxOutput)
+		if err != nil {
+			return err
+		}
+		intentHandlers.TurnLimits.MaxOutput = value
+	}
+	if budget := os.Getenv("TURN_BUDGET"); budget != "" {
+		value, err := time.ParseDuration(budget)
+		if err != nil {
+			return err
+		}
+		intentHandlers.TurnLimits.Budget = value
+	}
+	if maxRunaway := os.Getenv("MAX_RUNAWAY_EVALUATIONS"); maxRunaway != "" {
+		value, err := strconv.Atoi(maxRunaway)
+		if err != nil {
+			return err
+		}
+		intentHandlers.TurnLimits.MaxRunaway = value
+	}
+	limits := intentHandlers.TurnLimits
+	if limits.MaxBundles <= 0 || limits.MaxOutput <= 0 || limits.Budget <= 0 || limits.MaxRunaway <= 0 {
+		return fmt.Errorf("turn limits must be positive: %+v", limits)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+-- Named save slots of users, used when SAVE_STORE=postgres
+CREATE TABLE IF NOT EXISTS user_saves (
+	"UserID" TEXT NOT NULL,
+	"ProjectID" UUID NOT NULL,
+	"Name" TEXT NOT NULL,
+	"Save" JSONB NOT NULL,
+	"SavedAt" TIMESTAMP NOT NULL,
+	PRIMARY KEY ("UserID", "ProjectID", "Name")
+);
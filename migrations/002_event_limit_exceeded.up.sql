@@ -0,0 +1,12 @@
+-- Turns whose dialog logic was ended early by an execution limit
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS event_limit_exceeded (
+	"ID" UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	"EventUserActionID" UUID NOT NULL REFERENCES event_user_action ("ID"),
+	"ProjectID" UUID NOT NULL,
+	"Limit" TEXT NOT NULL,
+	"CreatedAt" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS event_limit_exceeded_project ON event_limit_exceeded ("ProjectID", "CreatedAt");
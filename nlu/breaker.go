@@ -0,0 +1,57 @@
+package nlu
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker is a circuit breaker which stops calls to a failing service
+// It opens after Threshold consecutive failures, then lets a single trial call
+// through once Cooldown has passed. A successful trial closes it again
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker creates a closed Breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may be made
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.Threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.Cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Success records a successful call and closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call, opening the breaker once the threshold is reached
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+	}
+}
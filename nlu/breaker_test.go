@@ -0,0 +1,77 @@
+package nlu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		// call is "allow", "success", "failure" or "wait"
+		call string
+		// allowed is the expected result of "allow"
+		allowed bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"closed while below threshold", []step{
+			{call: "failure"},
+			{call: "allow", allowed: true},
+		}},
+		{"opens at threshold", []step{
+			{call: "failure"},
+			{call: "failure"},
+			{call: "allow", allowed: false},
+		}},
+		{"success resets the count", []step{
+			{call: "failure"},
+			{call: "success"},
+			{call: "failure"},
+			{call: "allow", allowed: true},
+		}},
+		{"single trial after cooldown", []step{
+			{call: "failure"},
+			{call: "failure"},
+			{call: "wait"},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: false},
+		}},
+		{"successful trial closes", []step{
+			{call: "failure"},
+			{call: "failure"},
+			{call: "wait"},
+			{call: "allow", allowed: true},
+			{call: "success"},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+		}},
+		{"failed trial reopens", []step{
+			{call: "failure"},
+			{call: "failure"},
+			{call: "wait"},
+			{call: "allow", allowed: true},
+			{call: "failure"},
+			{call: "allow", allowed: false},
+		}},
+	}
+
+	for _, test := range tests {
+		b := NewBreaker(2, 10*time.Millisecond)
+		for i, s := range test.steps {
+			switch s.call {
+			case "allow":
+				if got := b.Allow(); got != s.allowed {
+					t.Errorf("%v: step %v: Allow() = %v, want %v", test.name, i, got, s.allowed)
+				}
+			case "success":
+				b.Success()
+			case "failure":
+				b.Failure()
+			case "wait":
+				time.Sleep(15 * time.Millisecond)
+			}
+		}
+	}
+}
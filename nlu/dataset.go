@@ -0,0 +1,70 @@
+package nlu
+
+import (
+	"encoding/json"
+	"strings"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/core/redis"
+)
+
+// Dataset is the snips training dataset compiled for a context
+// It is stored in redis under the context key, which is where kalidasa trains from
+type Dataset struct {
+	Language string                   `json:"language"`
+	Intents  map[string]DatasetIntent `json:"intents"`
+}
+
+// DatasetIntent holds the training utterances of a single intent
+type DatasetIntent struct {
+	Utterances []DatasetUtterance `json:"utterances"`
+}
+
+// Example returns the first training phrase of the intent, or an empty string if it has none
+func (i DatasetIntent) Example() string {
+	for _, utterance := range i.Utterances {
+		if text := utterance.Text(); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// DatasetUtterance is a training phrase split into text and slot chunks
+type DatasetUtterance struct {
+	Data []DatasetChunk `json:"data"`
+}
+
+// DatasetChunk is a piece of a training phrase
+// Entity and SlotName are set when the chunk is a slot example
+type DatasetChunk struct {
+	Text     string `json:"text"`
+	Entity   string `json:"entity,omitempty"`
+	SlotName string `json:"slot_name,omitempty"`
+}
+
+// Text joins the chunks into the full training phrase
+func (u DatasetUtterance) Text() string {
+	parts := make([]string, len(u.Data))
+	for i, chunk := range u.Data {
+		parts[i] = chunk.Text
+	}
+	return strings.TrimSpace(strings.Join(parts, ""))
+}
+
+// LoadDataset fetches the compiled training dataset of the context stored under key
+// A context without training data yields an empty dataset
+func LoadDataset(key string) (*Dataset, error) {
+	dataset := &Dataset{Intents: map[string]DatasetIntent{}}
+	raw, err := redis.Instance.Get(key).Bytes()
+	if err == goredis.Nil {
+		return dataset, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, dataset); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
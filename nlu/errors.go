@@ -0,0 +1,24 @@
+package nlu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCircuitOpen occurs when kalidasa has failed repeatedly and is not being called
+var ErrCircuitOpen = errors.New("nlu: circuit open")
+
+// UnavailableError occurs when no intent matcher could parse the query
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("nlu: unavailable: %v", e.Err)
+}
+
+// IsUnavailable reports whether err means the NLU could not be reached at all
+func IsUnavailable(err error) bool {
+	_, ok := err.(*UnavailableError)
+	return ok
+}
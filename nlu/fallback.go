@@ -0,0 +1,70 @@
+package nlu
+
+import (
+	"context"
+	"log"
+	"time"
+
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// DefaultPrimaryShare is the part of the time left to a turn the primary matcher may take
+// The rest is kept for the secondary, so a hung kalidasa can't use up the whole response budget
+const DefaultPrimaryShare = 0.75
+
+// Fallback is an IntentMatcher which defers to Secondary
+// whenever Primary fails, such as when kalidasa times out or is down
+// When both fail the error is an *UnavailableError
+type Fallback struct {
+	Primary   IntentMatcher
+	Secondary IntentMatcher
+	// PrimaryShare is the part of the time left before the deadline of ctx given to Primary
+	PrimaryShare float64
+}
+
+// NewFallback creates a Fallback matcher
+func NewFallback(primary, secondary IntentMatcher) *Fallback {
+	return &Fallback{Primary: primary, Secondary: secondary, PrimaryShare: DefaultPrimaryShare}
+}
+
+// primaryContext gives the primary matcher its own deadline, ahead of the one of ctx
+func (f *Fallback) primaryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || f.PrimaryShare <= 0 || f.PrimaryShare >= 1 {
+		return context.WithCancel(ctx)
+	}
+	left := deadline.Sub(time.Now())
+	return context.WithTimeout(ctx, time.Duration(float64(left)*f.PrimaryShare))
+}
+
+// Match tries the primary matcher before the secondary
+func (f *Fallback) Match(ctx context.Context, key, query string) (*snips.Result, error) {
+	primaryCtx, cancel := f.primaryContext(ctx)
+	result, err := f.Primary.Match(primaryCtx, key, query)
+	cancel()
+	if err == nil {
+		return result, nil
+	}
+	log.Printf("nlu: primary matcher failed, falling back: %v", err)
+	result, err = f.Secondary.Match(ctx, key, query)
+	if err != nil {
+		return nil, &UnavailableError{Err: err}
+	}
+	return result, nil
+}
+
+// MatchN tries the primary matcher before the secondary
+func (f *Fallback) MatchN(ctx context.Context, key, query string, n int) ([]snips.Result, error) {
+	primaryCtx, cancel := f.primaryContext(ctx)
+	results, err := MatchN(primaryCtx, f.Primary, key, query, n)
+	cancel()
+	if err == nil {
+		return results, nil
+	}
+	log.Printf("nlu: primary matcher failed, falling back: %v", err)
+	results, err = MatchN(ctx, f.Secondary, key, query, n)
+	if err != nil {
+		return nil, &UnavailableError{Err: err}
+	}
+	return results, nil
+}
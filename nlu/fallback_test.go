@@ -0,0 +1,41 @@
+package nlu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// stubMatcher answers with name, or waits for the deadline when name is empty
+type stubMatcher struct {
+	name string
+}
+
+func (s *stubMatcher) Match(ctx context.Context, key, query string) (*snips.Result, error) {
+	if s.name == "" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	result := &snips.Result{Input: query}
+	result.Intent.Name = s.name
+	return result, nil
+}
+
+func TestFallbackBudget(t *testing.T) {
+	f := NewFallback(&stubMatcher{}, &stubMatcher{name: "secondary"})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, err := f.Match(ctx, "context", "hello")
+	if err != nil {
+		t.Fatalf("Match() error = %v", err)
+	}
+	if result.Intent.Name != "secondary" {
+		t.Errorf("Match() = %q, want the secondary", result.Intent.Name)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("the primary used up the whole budget")
+	}
+}
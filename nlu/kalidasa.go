@@ -0,0 +1,197 @@
+package nlu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/talkative-ai/brahman/locale"
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// DefaultKalidasaAddr is the address of the kalidasa service within the cluster
+const DefaultKalidasaAddr = "http://kalidasa:8080"
+
+const (
+	// DefaultKalidasaTimeout bounds a single attempt so that a hung kalidasa
+	// is treated as unavailable rather than stalling the conversation
+	// Two attempts fit in the share of a 4s response budget a Fallback gives kalidasa
+	DefaultKalidasaTimeout = time.Second
+	// DefaultKalidasaRetries is how many times a failed attempt is retried
+	DefaultKalidasaRetries = 2
+	// DefaultKalidasaBackoff is the base delay between attempts, doubled every retry
+	DefaultKalidasaBackoff = 100 * time.Millisecond
+)
+
+// kalidasaClient is shared by every Kalidasa matcher so connections are reused
+var kalidasaClient = &http.Client{}
+
+// Kalidasa is an IntentMatcher backed by the kalidasa NLU service
+// Failed attempts are retried with jittered backoff while the deadline allows,
+// and the Breaker stops calls altogether while kalidasa keeps failing
+type Kalidasa struct {
+	Addr    string
+	Client  *http.Client
+	Timeout time.Duration
+	Retries int
+	Backoff time.Duration
+	Breaker *Breaker
+}
+
+// NewKalidasa creates a Kalidasa matcher for the service at addr
+// An empty addr falls back to DefaultKalidasaAddr
+func NewKalidasa(addr string) *Kalidasa {
+	if addr == "" {
+		addr = DefaultKalidasaAddr
+	}
+	return &Kalidasa{
+		Addr:    strings.TrimSuffix(addr, "/"),
+		Client:  kalidasaClient,
+		Timeout: DefaultKalidasaTimeout,
+		Retries: DefaultKalidasaRetries,
+		Backoff: DefaultKalidasaBackoff,
+		Breaker: NewBreaker(5, 30*time.Second),
+	}
+}
+
+// Match parses the query with the model trained for the context stored under key
+func (k *Kalidasa) Match(ctx context.Context, key, query string) (*snips.Result, error) {
+	var result snips.Result
+	if err := k.post(ctx, "/v1/parse", key, query, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MatchN parses the query, then asks kalidasa for the runner-up intents
+// The runner-ups come from /v1/intents, which takes the same form as /v1/parse
+// and replies with a JSON array of the intents of the model scored against the query,
+// like the intent of a parse. They are ranked here, so their order doesn't matter
+// A kalidasa without the endpoint replies 404, and only the best intent is returned
+func (k *Kalidasa) MatchN(ctx context.Context, key, query string, n int) ([]snips.Result, error) {
+	best, err := k.Match(ctx, key, query)
+	if err != nil {
+		return nil, err
+	}
+	results := []snips.Result{*best}
+	if n <= 1 {
+		return results, nil
+	}
+
+	// The runner-ups are a bonus on top of a good parse, so failing to get them
+	// neither fails the match nor counts against kalidasa's health
+	var intents []snips.Intent
+	if _, err := k.retry(ctx, "/v1/intents", key, query, &intents); err != nil {
+		log.Printf("kalidasa: fetching runner-up intents: %v", err)
+		return results, nil
+	}
+	sort.SliceStable(intents, func(i, j int) bool {
+		return intents[i].Probability > intents[j].Probability
+	})
+	for _, intent := range intents {
+		if len(results) >= n {
+			break
+		}
+		if intent.Name == "" || intent.Name == best.Intent.Name {
+			continue
+		}
+		results = append(results, snips.Result{Input: query, Intent: intent})
+	}
+	return results, nil
+}
+
+// post sends the query and context key to a kalidasa endpoint and decodes the reply into v
+func (k *Kalidasa) post(ctx context.Context, path, key, query string, v interface{}) error {
+	// A turn which already ran out of time says nothing about kalidasa
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if k.Breaker != nil && !k.Breaker.Allow() {
+		return ErrCircuitOpen
+	}
+
+	transient, err := k.retry(ctx, path, key, query, v)
+	if k.Breaker != nil {
+		// Only transport errors, timeouts and 5xx mean kalidasa is unhealthy
+		// A 4xx or a reply which can't be decoded still came from a kalidasa which is up
+		if transient {
+			k.Breaker.Failure()
+		} else {
+			k.Breaker.Success()
+		}
+	}
+	return err
+}
+
+// retry makes attempts at the request with jittered backoff until one succeeds,
+// a failure isn't worth retrying, or the deadline passes
+// transient reports whether it failed in a way worth retrying, as when kalidasa is down
+func (k *Kalidasa) retry(ctx context.Context, path, key, query string, v interface{}) (transient bool, err error) {
+	for attempt := 0; attempt <= k.Retries; attempt++ {
+		if attempt > 0 && k.Backoff > 0 {
+			// Full jitter keeps retries from many turns from arriving in lockstep
+			delay := time.Duration(rand.Int63n(int64(k.Backoff << uint(attempt))))
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+		}
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			return true, err
+		}
+
+		transient, err = k.attempt(ctx, path, key, query, v)
+		if err == nil || !transient {
+			break
+		}
+	}
+	return transient, err
+}
+
+// attempt makes a single request to kalidasa
+// retry reports whether the failure is worth trying again
+func (k *Kalidasa) attempt(ctx context.Context, path, key, query string, v interface{}) (retry bool, err error) {
+	data := url.Values{}
+	data.Set("query", query)
+	data.Set("context", key)
+	if l := locale.From(ctx); l != "" {
+		data.Set("locale", l)
+	}
+	encoded := data.Encode()
+
+	rq, err := http.NewRequest("POST", fmt.Sprintf("%v%v", k.Addr, path), strings.NewReader(encoded))
+	if err != nil {
+		return false, err
+	}
+	rq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rq.Header.Add("Content-Length", strconv.Itoa(len(encoded)))
+
+	attemptCtx, cancel := context.WithTimeout(ctx, k.Timeout)
+	defer cancel()
+
+	resp, err := k.Client.Do(rq.WithContext(attemptCtx))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return true, fmt.Errorf("kalidasa: unexpected status %v from %v", resp.StatusCode, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("kalidasa: unexpected status %v from %v", resp.StatusCode, path)
+	}
+
+	return false, json.NewDecoder(resp.Body).Decode(v)
+}
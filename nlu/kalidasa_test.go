@@ -0,0 +1,102 @@
+package nlu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKalidasaBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		// status and body are the reply of kalidasa to every request
+		status int
+		body   string
+		// open is whether the breaker should open after repeated calls
+		open bool
+	}{
+		{"ok", http.StatusOK, `{"input": "hello"}`, false},
+		{"bad request", http.StatusBadRequest, `{"error": "no model"}`, false},
+		{"not found", http.StatusNotFound, ``, false},
+		{"invalid json", http.StatusOK, `{"input": `, false},
+		{"server error", http.StatusInternalServerError, ``, true},
+		{"unavailable", http.StatusServiceUnavailable, ``, true},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		k := NewKalidasa(server.URL)
+		k.Retries = 0
+		k.Breaker = NewBreaker(2, time.Minute)
+		for i := 0; i < 3; i++ {
+			k.Match(context.Background(), "context", "hello")
+		}
+		if got := !k.Breaker.Allow(); got != test.open {
+			t.Errorf("%v: breaker open = %v, want %v", test.name, got, test.open)
+		}
+		server.Close()
+	}
+}
+
+func TestKalidasaExpiredContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	k := NewKalidasa(server.URL)
+	k.Breaker = NewBreaker(1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := k.Match(ctx, "context", "hello"); err == nil {
+		t.Errorf("Match() with an expired context succeeded")
+	}
+	if !k.Breaker.Allow() {
+		t.Errorf("breaker opened by a turn which ran out of time")
+	}
+}
+
+func TestKalidasaMatchN(t *testing.T) {
+	tests := []struct {
+		name string
+		// intents is the reply of /v1/intents, which is missing when empty
+		intents string
+		want    []string
+	}{
+		{"ranked", `[{"intentName": "open", "probability": 0.9}, {"intentName": "close", "probability": 0.3}, {"intentName": "look", "probability": 0.5}]`, []string{"open", "look", "close"}},
+		{"missing endpoint", "", []string{"open"}},
+		{"invalid json", `[{"intentName": `, []string{"open"}},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/parse":
+				w.Write([]byte(`{"input": "open it", "intent": {"intentName": "open", "probability": 0.9}}`))
+			case r.URL.Path == "/v1/intents" && test.intents != "":
+				w.Write([]byte(test.intents))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		results, err := NewKalidasa(server.URL).MatchN(context.Background(), "context", "open it", 3)
+		server.Close()
+		if err != nil {
+			t.Errorf("%v: MatchN() error = %v", test.name, err)
+			continue
+		}
+		got := []string{}
+		for _, result := range results {
+			got = append(got, result.Intent.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: MatchN() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
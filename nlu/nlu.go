@@ -0,0 +1,39 @@
+package nlu
+
+import (
+	"context"
+
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// IntentMatcher parses a query against the compiled training context stored under key
+// and returns the most probable intent
+// Matchers must give up once ctx is done, so that a turn stays within the platform's response budget
+type IntentMatcher interface {
+	Match(ctx context.Context, key, query string) (*snips.Result, error)
+}
+
+// RankedMatcher is an IntentMatcher which can also return
+// the n most probable intents, best first
+type RankedMatcher interface {
+	IntentMatcher
+	MatchN(ctx context.Context, key, query string, n int) ([]snips.Result, error)
+}
+
+// MatchN returns up to n results from the matcher, best first
+// Matchers which can't rank return only their single best result
+func MatchN(ctx context.Context, m IntentMatcher, key, query string, n int) ([]snips.Result, error) {
+	if ranked, ok := m.(RankedMatcher); ok {
+		return ranked.MatchN(ctx, key, query, n)
+	}
+	result, err := m.Match(ctx, key, query)
+	if err != nil {
+		return nil, err
+	}
+	return []snips.Result{*result}, nil
+}
+
+// Matcher is the IntentMatcher used throughout Brahman
+// It may be replaced to swap the NLU backend, or with a fake in tests
+// By default kalidasa is used, degrading to in-process matching when it fails
+var Matcher IntentMatcher = NewFallback(NewKalidasa(""), &Offline{})
@@ -0,0 +1,137 @@
+package nlu
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	snips "github.com/talkative-ai/snips-nlu-types"
+)
+
+// Offline is an in-process IntentMatcher
+// It scores the query against the training phrases of the context with
+// fuzzy keyword overlap. It is far less accurate than kalidasa, but it
+// keeps conversations going while the NLU service is unavailable
+type Offline struct{}
+
+// Match finds the intent with the training phrase most similar to the query
+func (o *Offline) Match(ctx context.Context, key, query string) (*snips.Result, error) {
+	results, err := o.MatchN(ctx, key, query, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &snips.Result{Input: query}, nil
+	}
+	return &results[0], nil
+}
+
+// MatchN ranks intents by their training phrase most similar to the query
+// It is quick and local, so it answers even once the deadline of ctx has passed
+func (o *Offline) MatchN(ctx context.Context, key, query string, n int) ([]snips.Result, error) {
+	dataset, err := LoadDataset(key)
+	if err != nil {
+		return nil, err
+	}
+
+	queryTokens := tokenize(query)
+	results := []snips.Result{}
+	for name, intent := range dataset.Intents {
+		result := snips.Result{Input: query}
+		result.Intent.Name = name
+		for _, utterance := range intent.Utterances {
+			score := similarity(queryTokens, tokenize(utterance.Text()))
+			if score > result.Intent.Probability {
+				result.Intent.Probability = score
+			}
+		}
+		if result.Intent.Probability > 0 {
+			results = append(results, result)
+		}
+	}
+
+	// Ties are sorted by name so that they always resolve to the same intent
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Intent.Probability != results[j].Intent.Probability {
+			return results[i].Intent.Probability > results[j].Intent.Probability
+		}
+		return results[i].Intent.Name < results[j].Intent.Name
+	})
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	return results, nil
+}
+
+// Similarity scores how alike two phrases are, from 0 to 1
+func Similarity(a, b string) float64 {
+	return similarity(tokenize(a), tokenize(b))
+}
+
+// tokenize lowercases the text and splits it into words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+}
+
+// similarity is the Dice coefficient of two token lists,
+// where tokens count as equal when they are a small edit apart
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	used := make([]bool, len(b))
+	matched := 0
+	for _, token := range a {
+		for i, other := range b {
+			if !used[i] && fuzzyEqual(token, other) {
+				used[i] = true
+				matched++
+				break
+			}
+		}
+	}
+	return 2 * float64(matched) / float64(len(a)+len(b))
+}
+
+// fuzzyEqual tolerates one typo in words of four or more letters
+func fuzzyEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) < 4 || len(b) < 4 {
+		return false
+	}
+	return levenshtein(a, b) <= 1
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package nlu
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"Open the door!", []string{"open", "the", "door"}},
+		{"don't go, 2 steps", []string{"don't", "go", "2", "steps"}},
+	}
+	for _, test := range tests {
+		got := tokenize(test.text)
+		if len(got) == 0 && len(test.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "open the door", 0},
+		{"open the door", "open the door", 1},
+		{"Open the door", "open THE door!", 1},
+		{"open the door", "close the window", 1.0 / 3},
+		{"open the door", "open door", 0.8},
+		// One typo is tolerated in longer words
+		{"opne the door", "open the door", 2.0 / 3},
+		{"open the dooor", "open the door", 1},
+		// But not in short words
+		{"teh", "the", 0},
+		{"take the key", "go north", 0},
+	}
+	for _, test := range tests {
+		if got := Similarity(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"door", "door", 0},
+		{"door", "dooor", 1},
+		{"door", "odor", 2},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
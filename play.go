@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/models"
+	ssml "github.com/talkative-ai/go-ssml"
+)
+
+// playCommand plays an app in the terminal, through the same pipeline as the routes
+// Usage: brahman play [-locale es-ES] [-user {userID}] {projectID|demo:projectID}
+// The demo: prefix plays the app as last compiled in the workbench rather than as published
+// An empty line is taken as the user staying silent. The player quits at the end of input
+func playCommand(args []string) error {
+	flags := flag.NewFlagSet("play", flag.ContinueOnError)
+	userLocale := flags.String("locale", "", "locale of the player, e.g. es-ES")
+	userID := flags.String("user", "", "ID of the player, to resume progress and use saves")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: brahman play [-locale es-ES] [-user {userID}] {projectID|demo:projectID}")
+	}
+
+	projectID, demo, _, err := intentHandlers.ParsePubID(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bad project ID: %v", err)
+	}
+	if !demo {
+		published, err := intentHandlers.IsPublished(projectID)
+		if err != nil {
+			return err
+		}
+		if !published {
+			return fmt.Errorf("project %v isn't published, play it as %v%v instead", projectID, intentHandlers.DemoPubIDPrefix, projectID)
+		}
+	}
+
+	ctx := locale.With(context.Background(), *userLocale)
+	if *userID != "" {
+		ctx = intentHandlers.WithUserID(ctx, fmt.Sprintf("play:%v", *userID))
+	}
+
+	state := models.MutableAIRequestState{}
+	ended := playTurn(ctx, &state, &intentHandlers.Turn{New: true, ProjectID: projectID, Demo: demo})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for !ended {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+		input := strings.TrimSpace(scanner.Text())
+		ended = playTurn(ctx, &state, &intentHandlers.Turn{Input: input, NoInput: input == ""})
+	}
+	return scanner.Err()
+}
+
+// playTurn runs the turn and prints its output, reporting whether the conversation ended
+// A failed turn is apologized for like on any platform, and play carries on
+func playTurn(ctx context.Context, state *models.MutableAIRequestState, turn *intentHandlers.Turn) bool {
+	message := &models.AIRequest{
+		State:      *state,
+		OutputSSML: ssml.NewBuilder(),
+	}
+	// The app is all there is to play, so stopping it ends the conversation
+	turn.StopEnds = true
+	turn.Request = message
+
+	if err := turn.Run(ctx); err != nil {
+		log.Println("Error", err)
+		apology := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+		if intentHandlers.Apologize(ctx, err, apology) == intentHandlers.ErrorStateCorrupt {
+			*state = models.MutableAIRequestState{}
+		}
+		fmt.Println(strings.TrimSpace(intentHandlers.PlainText(apology.OutputSSML.Raw())))
+		return false
+	}
+
+	output := message.OutputSSML.Raw()
+	if turn.Repeat {
+		output = message.State.PreviousResponse
+	}
+	fmt.Println(strings.TrimSpace(intentHandlers.PlainText(output)))
+	turn.Finish(ctx, output)
+	*state = message.State
+	return turn.End
+}
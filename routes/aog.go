@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/talkative-ai/aog"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/core"
+	"github.com/talkative-ai/core/models"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// conversationTokenTTL is how long the state carried in a conversation token stays valid
+const conversationTokenTTL = time.Minute * 3
+
+// newConversationToken signs the state into the token carried by AoG from one turn to the next
+func newConversationToken(state models.MutableAIRequestState) (string, error) {
+	return newStateToken(state, conversationTokenTTL)
+}
+
+// newStateToken signs the state into a token valid for ttl
+// Platforms which carry the state on the client side hold it as such a token
+func newStateToken(state models.MutableAIRequestState, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp":  time.Now().Add(ttl).Unix(),
+		"data": state,
+	})
+	// Sign the token and stringify
+	return token.SignedString([]byte(os.Getenv("JWT_KEY")))
+}
+
+// parseConversationToken reads the state carried by a conversation token
+// New conversations have no token, and start with an empty state
+func parseConversationToken(conversationToken string) (models.MutableAIRequestState, error) {
+	if conversationToken == "" {
+		return models.MutableAIRequestState{}, nil
+	}
+	stateMap, err := utilities.ParseJTWClaims(conversationToken)
+	if err != nil {
+		return models.MutableAIRequestState{}, intentHandlers.StateCorrupt(err)
+	}
+	if data, ok := stateMap["data"].(map[string]interface{}); ok {
+		stateMap = data
+	}
+
+	// Outside of an app the conversation is with Talkative, which keeps no state
+	// but its session, e.g. counting the turns the user stayed silent
+	if projectID, ok := stateMap["ProjectID"].(string); !ok || projectID == uuid.Nil.String() {
+		sessionID, _ := stateMap["SessionID"].(string)
+		return models.MutableAIRequestState{SessionID: uuid.FromStringOrNil(sessionID)}, nil
+	}
+	return parseGoogleState(stateMap)
+}
+
+// simpleResponse returns the SSML and display text of the first simple response of the expected inputs
+// The expected inputs are expected to have been decoded from JSON
+func simpleResponse(expectedInputs []aog.ExpectedInput) (string, string) {
+	if len(expectedInputs) == 0 || len(expectedInputs[0].InputPrompt.RichInitialPrompt.Items) == 0 {
+		return "", ""
+	}
+	item, _ := expectedInputs[0].InputPrompt.RichInitialPrompt.Items[0].(map[string]interface{})
+	simple, _ := item["simpleResponse"].(map[string]interface{})
+	ssml, _ := simple["ssml"].(string)
+	text, _ := simple["displayText"].(string)
+	return ssml, text
+}
+
+// parseGoogleState reads the state of an app from the claims of the conversation token
+// Claims which aren't shaped as expected mean the state is corrupt
+func parseGoogleState(stateMap map[string]interface{}) (models.MutableAIRequestState, error) {
+	claims := &stateClaims{values: stateMap}
+	state := models.MutableAIRequestState{
+		Demo:             claims.boolClaim("Demo"),
+		SessionID:        uuid.FromStringOrNil(claims.stringClaim("SessionID")),
+		Zone:             uuid.FromStringOrNil(claims.stringClaim("Zone")),
+		PubID:            claims.stringClaim("PubID"),
+		ProjectID:        uuid.FromStringOrNil(claims.stringClaim("ProjectID")),
+		PreviousResponse: claims.stringClaim("PreviousResponse"),
+		RestartRequested: claims.boolClaim("RestartRequested"),
+	}
+	if claims.err != nil {
+		return state, claims.err
+	}
+
+	state.ZoneActors = map[uuid.UUID][]string{}
+	if stateMap["ZoneActors"] != nil {
+		zoneActors, ok := stateMap["ZoneActors"].(map[string]interface{})
+		if !ok {
+			return state, malformedState("ZoneActors")
+		}
+		for zone, actors := range zoneActors {
+			actorList, ok := actors.([]interface{})
+			if !ok {
+				return state, malformedState("ZoneActors")
+			}
+			zoneID := uuid.FromStringOrNil(zone)
+			state.ZoneActors[zoneID] = []string{}
+			for _, actor := range actorList {
+				actorID, ok := actor.(string)
+				if !ok {
+					return state, malformedState("ZoneActors")
+				}
+				state.ZoneActors[zoneID] = append(state.ZoneActors[zoneID], actorID)
+			}
+		}
+	}
+	if stateMap["CurrentDialog"] != nil {
+		currentDialog, ok := stateMap["CurrentDialog"].(string)
+		if !ok {
+			return state, malformedState("CurrentDialog")
+		}
+		state.CurrentDialog = &currentDialog
+	}
+
+	// TODO: Generalize this and create consistency between brahman/intent_handlers
+	if stateMap["ZoneInitialized"] != nil {
+		zoneInitialized, ok := stateMap["ZoneInitialized"].(map[string]interface{})
+		if !ok {
+			return state, malformedState("ZoneInitialized")
+		}
+		state.ZoneInitialized = map[uuid.UUID]bool{}
+		for key, item := range zoneInitialized {
+			initialized, ok := item.(bool)
+			if !ok {
+				return state, malformedState("ZoneInitialized")
+			}
+			state.ZoneInitialized[uuid.FromStringOrNil(key)] = initialized
+		}
+	}
+	return state, nil
+}
+
+// stateClaims reads typed values from the claims of a conversation token
+// The first claim which isn't of the expected type is kept in err, so it's checked once after reading
+type stateClaims struct {
+	values map[string]interface{}
+	err    error
+}
+
+func (c *stateClaims) stringClaim(name string) string {
+	value, ok := c.values[name].(string)
+	if !ok && c.err == nil {
+		c.err = malformedState(name)
+	}
+	return value
+}
+
+func (c *stateClaims) boolClaim(name string) bool {
+	value, ok := c.values[name].(bool)
+	if !ok && c.err == nil {
+		c.err = malformedState(name)
+	}
+	return value
+}
+
+// malformedState is the error for a claim of the state which isn't shaped as expected
+func malformedState(field string) error {
+	return intentHandlers.StateCorrupt(fmt.Errorf("malformed state: %v", field))
+}
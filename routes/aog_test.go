@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/talkative-ai/brahman/intent_handlers"
+)
+
+func TestParseGoogleState(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"Demo":             false,
+			"SessionID":        "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			"Zone":             "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+			"PubID":            "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+			"ProjectID":        "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+			"PreviousResponse": "",
+			"RestartRequested": false,
+			"CurrentDialog":    "dialog",
+			"ZoneActors": map[string]interface{}{
+				"6ba7b811-9dad-11d1-80b4-00c04fd430c8": []interface{}{"actor"},
+			},
+			"ZoneInitialized": map[string]interface{}{
+				"6ba7b811-9dad-11d1-80b4-00c04fd430c8": true,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(map[string]interface{})
+		corrupt bool
+	}{
+		{"valid", func(map[string]interface{}) {}, false},
+		{"no current dialog", func(m map[string]interface{}) { m["CurrentDialog"] = nil }, false},
+		{"missing field", func(m map[string]interface{}) { delete(m, "Demo") }, true},
+		{"wrong type", func(m map[string]interface{}) { m["PubID"] = 1.0 }, true},
+		{"malformed actors", func(m map[string]interface{}) { m["ZoneActors"] = []interface{}{} }, true},
+		{"malformed actor", func(m map[string]interface{}) {
+			m["ZoneActors"] = map[string]interface{}{"zone": []interface{}{1.0}}
+		}, true},
+		{"malformed initialized", func(m map[string]interface{}) {
+			m["ZoneInitialized"] = map[string]interface{}{"zone": "yes"}
+		}, true},
+	}
+	for _, test := range tests {
+		stateMap := valid()
+		test.change(stateMap)
+		state, err := parseGoogleState(stateMap)
+		if test.corrupt {
+			if intentHandlers.KindOf(err) != intentHandlers.ErrorStateCorrupt {
+				t.Errorf("%v: parseGoogleState() error = %v, want a corrupt state", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: parseGoogleState() error = %v", test.name, err)
+			continue
+		}
+		if state.PubID != stateMap["PubID"] || len(state.ZoneActors) != 1 {
+			t.Errorf("%v: parseGoogleState() = %+v", test.name, state)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/talkative-ai/core"
+	"github.com/talkative-ai/core/myerrors"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// workbenchUserHeader carries the ID of the workbench user to the handler, once authenticated
+const workbenchUserHeader = "X-User-ID"
+
+// workbenchTokenData is the data the workbench signs into its x-token
+type workbenchTokenData struct {
+	ID uuid.UUID
+}
+
+// requireWorkbenchAuth is a prehandle.Prehandler rejecting requests without a valid workbench token
+// The token is the one the workbench sends in the x-token header
+func requireWorkbenchAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := workbenchUser(r.Header.Get("x-token"))
+		if err != nil || userID == uuid.Nil {
+			myerrors.Respond(w, &myerrors.MySimpleError{
+				Code:    http.StatusUnauthorized,
+				Message: "unauthorized",
+				Req:     r,
+			})
+			return
+		}
+		r.Header.Set(workbenchUserHeader, userID.String())
+		handler(w, r)
+	}
+}
+
+// workbenchUser returns the ID of the user the workbench token was issued to
+func workbenchUser(token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.Nil, nil
+	}
+	claims, err := utilities.ParseJTWClaims(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	raw, err := json.Marshal(claims["data"])
+	if err != nil {
+		return uuid.Nil, err
+	}
+	data := workbenchTokenData{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return uuid.Nil, err
+	}
+	return data.ID, nil
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/talkative-ai/aog"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/myerrors"
+	"github.com/talkative-ai/go-alexa/skillserver"
+	ssml "github.com/talkative-ai/go-ssml"
+)
+
+// errorStatus is the HTTP status the demo API responds with for each kind of error
+var errorStatus = map[intentHandlers.ErrorKind]int{
+	intentHandlers.ErrorNLUUnavailable: http.StatusServiceUnavailable,
+	intentHandlers.ErrorStateCorrupt:   http.StatusBadRequest,
+	intentHandlers.ErrorDialogMissing:  http.StatusNotFound,
+	intentHandlers.ErrorDatabase:       http.StatusServiceUnavailable,
+	intentHandlers.ErrorInternal:       http.StatusInternalServerError,
+}
+
+// errorMetadataKey is where the kind of error is given in the response metadata of AoG responses
+const errorMetadataKey = "error"
+
+// respondGoogleError speaks an apology for err in a valid AoG response
+// The conversation token is kept so the user can simply try again,
+// unless the state it carries is what failed
+func respondGoogleError(ctx context.Context, w http.ResponseWriter, err error, conversationToken string) {
+	message := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+	kind := intentHandlers.Apologize(ctx, err, message)
+	if kind == intentHandlers.ErrorStateCorrupt {
+		conversationToken = ""
+	}
+	response := aog.NewResponse(conversationToken, message.OutputSSML.String(), message.OutputSSML.Raw(), true)
+	response.ResponseMetadata[errorMetadataKey] = kind
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondAlexaError speaks an apology for err in a valid Alexa response
+// The session ends when its state is what failed, so the next one starts afresh
+func respondAlexaError(ctx context.Context, w http.ResponseWriter, err error) {
+	message := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+	kind := intentHandlers.Apologize(ctx, err, message)
+	echoResp := skillserver.NewEchoResponse().
+		OutputSpeechSSML(message.OutputSSML.String()).
+		EndSession(kind == intentHandlers.ErrorStateCorrupt)
+	json, _ := echoResp.String()
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Write(json)
+}
+
+// respondDialogflowError speaks an apology for err in a valid Dialogflow response
+// The state context is kept so the user can simply try again,
+// unless the state it carries is what failed, in which case it's cleared
+func respondDialogflowError(ctx context.Context, w http.ResponseWriter, err error, stateContext, stateToken string) {
+	message := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+	kind := intentHandlers.Apologize(ctx, err, message)
+	response := newDialogflowResponse(message.OutputSSML.String(), message.OutputSSML.Raw(), true)
+	if stateContext != "" {
+		state := dialogflowContext{Name: stateContext}
+		if kind != intentHandlers.ErrorStateCorrupt && stateToken != "" {
+			state.LifespanCount = dialogflowStateLifespan
+			state.Parameters = map[string]interface{}{"token": stateToken}
+		}
+		response.OutputContexts = []dialogflowContext{state}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondActionsError speaks an apology for err in a valid conversational actions response
+// The session params are kept so the user can simply try again,
+// unless the state they carry is what failed, in which case it's cleared
+func respondActionsError(ctx context.Context, w http.ResponseWriter, err error, session actionsSession) {
+	message := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+	kind := intentHandlers.Apologize(ctx, err, message)
+	response := &actionsResponse{
+		Prompt:  newActionsPrompt(message.OutputSSML.String(), message.OutputSSML.Raw()),
+		Session: actionsSession{ID: session.ID, Params: session.Params},
+	}
+	if kind == intentHandlers.ErrorStateCorrupt {
+		// A null param is how a session param is removed
+		response.Session.Params = map[string]json.RawMessage{actionsStateParam: json.RawMessage("null")}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondAPIError responds to the demo and chat APIs with the HTTP status for the kind of error
+func respondAPIError(w http.ResponseWriter, r *http.Request, kind intentHandlers.ErrorKind, log string) {
+	myerrors.Respond(w, &myerrors.MySimpleError{
+		Code:    errorStatus[kind],
+		Message: string(kind),
+		Req:     r,
+		Log:     log,
+	})
+}
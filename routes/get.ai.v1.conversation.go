@@ -0,0 +1,283 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/myerrors"
+	"github.com/talkative-ai/core/router"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// GetConversation router.Route
+// Path: "/ai/v1/conversation/{projectID}",
+// Method: "GET",
+// Upgrades to a WebSocket which accepts conversationInput messages
+// Responds with conversationOutput messages, pushing the output of each action bundle as it's produced
+var GetConversation = &router.Route{
+	Path:    "/ai/v1/conversation/{projectID:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}",
+	Method:  "GET",
+	Handler: http.HandlerFunc(getConversationHandler),
+}
+
+// WebSocketOrigins are the origins allowed to open a conversation, as for CORS
+var WebSocketOrigins = []string{}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			// Not a browser, so there's no page to protect
+			return true
+		}
+		for _, allowed := range WebSocketOrigins {
+			if strings.EqualFold(origin, allowed) {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+const (
+	// conversationTyping tells the client output is on its way, until the turn is done
+	conversationTyping = "typing"
+	// conversationPart carries output of the turn, in order
+	conversationPart = "part"
+	// conversationDone ends the turn, after which the next message is expected
+	conversationDone = "done"
+	// conversationError apologizes for a turn which failed
+	conversationError = "error"
+)
+
+type conversationInput struct {
+	Message string
+}
+
+type conversationOutput struct {
+	Type string
+	SSML string `json:",omitempty"`
+	Text string `json:",omitempty"`
+	// Suggestions are phrases the user could reply with, sent when the turn is done
+	Suggestions []string `json:",omitempty"`
+	// Ended is set when the app ended the conversation, after which the socket is closed
+	Ended bool `json:",omitempty"`
+	// Error is the kind of error a turn failed with
+	Error intentHandlers.ErrorKind `json:",omitempty"`
+}
+
+const (
+	// conversationReadLimit is the largest message accepted from the client
+	conversationReadLimit = 65535
+	// conversationWriteWait is how long the client has to accept a message
+	conversationWriteWait = 10 * time.Second
+	// conversationPongWait is how long the client has to answer a ping, or send a message
+	conversationPongWait = 60 * time.Second
+	// conversationPingPeriod is how often the client is pinged, within conversationPongWait
+	conversationPingPeriod = conversationPongWait * 9 / 10
+)
+
+// conversation is a WebSocket conversation with a published app
+// The state is kept with the connection, so the conversation lasts as long as the socket
+type conversation struct {
+	conn   *websocket.Conn
+	locale string
+	state  models.MutableAIRequestState
+}
+
+// conversationResponse is the whole output of a turn, kept as the previous response for "repeat"
+type conversationResponse struct {
+	SSML string
+	Text string
+}
+
+// getConversationHandler starts the app, then runs a turn for each message received
+// Query parameters: locale, e.g. "es-ES". Defaults to English
+func getConversationHandler(w http.ResponseWriter, r *http.Request) {
+
+	projectID, err := uuid.FromString(mux.Vars(r)["projectID"])
+	if err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_id",
+			Req:     r,
+		})
+		return
+	}
+	published, err := intentHandlers.IsPublished(projectID)
+	if err != nil {
+		respondAPIError(w, r, intentHandlers.KindOf(err), err.Error())
+		return
+	}
+	if !published {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusNotFound,
+			Message: "project_not_published",
+			Req:     r,
+		})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already responded with the error
+		fmt.Println("Error upgrading conversation", err)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(conversationReadLimit)
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(conversationPongWait))
+	})
+	stopPings := make(chan struct{})
+	defer close(stopPings)
+	go pingConversation(conn, stopPings)
+
+	c := &conversation{
+		conn:   conn,
+		locale: r.URL.Query().Get("locale"),
+	}
+	ended, err := c.turn(r, &intentHandlers.Turn{New: true, ProjectID: projectID})
+	for err == nil && !ended {
+		var input conversationInput
+		conn.SetReadDeadline(time.Now().Add(conversationPongWait))
+		if err = conn.ReadJSON(&input); err != nil {
+			break
+		}
+		ended, err = c.turn(r, &intentHandlers.Turn{Input: input.Message})
+	}
+	if err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		fmt.Println("Error in conversation", err)
+	}
+}
+
+// pingConversation pings the client until stop is closed, so a vanished client is noticed
+// Pings are control messages, which may be written concurrently with the turns
+func pingConversation(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(conversationPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(conversationWriteWait)); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// turn runs the turn, pushing its output to the client as it's produced
+// It reports whether the conversation ended, and returns an error only when the socket failed
+func (c *conversation) turn(r *http.Request, turn *intentHandlers.Turn) (bool, error) {
+	ctx := locale.With(r.Context(), c.locale)
+
+	// The output is written by its own goroutine, so a slow client doesn't hold up the dialog logic
+	// A turn outputs at most a typing indicator, a part per bundle, a last part and its end,
+	// so sending never blocks
+	outbox := make(chan conversationOutput, intentHandlers.TurnLimits.MaxBundles+3)
+	written := make(chan error, 1)
+	go c.write(outbox, written)
+	finish := func(last conversationOutput) error {
+		outbox <- last
+		close(outbox)
+		return <-written
+	}
+
+	pushed := ""
+	ctx = intentHandlers.WithOutputListener(ctx, func(raw string) {
+		pushed += raw
+		if part, ok := newConversationPart(raw); ok {
+			outbox <- part
+		}
+	})
+
+	message := &models.AIRequest{
+		State:      c.state,
+		OutputSSML: ssml.NewBuilder(),
+	}
+	// The app is all there is to the conversation, so stopping it ends the conversation
+	turn.StopEnds = true
+	turn.Request = message
+
+	outbox <- conversationOutput{Type: conversationTyping}
+	if err := turn.Run(ctx); err != nil {
+		apology := &models.AIRequest{OutputSSML: ssml.NewBuilder()}
+		kind := intentHandlers.Apologize(ctx, err, apology)
+		if kind == intentHandlers.ErrorStateCorrupt {
+			c.state = models.MutableAIRequestState{}
+		}
+		return false, finish(conversationOutput{
+			Type:  conversationError,
+			SSML:  apology.OutputSSML.String(),
+			Text:  intentHandlers.PlainText(apology.OutputSSML.Raw()),
+			Error: kind,
+		})
+	}
+
+	response := conversationResponse{}
+	if turn.Repeat {
+		if err := json.Unmarshal([]byte(message.State.PreviousResponse), &response); err != nil {
+			// Only what this turn output is lost, the conversation carries on
+			fmt.Println("Error reading previous response", err)
+		}
+		outbox <- conversationOutput{Type: conversationPart, SSML: response.SSML, Text: response.Text}
+	} else {
+		// Whatever wasn't output by an action bundle, e.g. the reply to a system intent
+		raw := message.OutputSSML.Raw()
+		if part, ok := newConversationPart(strings.TrimPrefix(raw, pushed)); ok && strings.HasPrefix(raw, pushed) {
+			outbox <- part
+		}
+		response.SSML = message.OutputSSML.String()
+		response.Text = intentHandlers.PlainText(raw)
+	}
+	previousResponseBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error keeping previous response", err)
+	}
+	turn.Finish(ctx, string(previousResponseBytes))
+	c.state = message.State
+
+	done := conversationOutput{Type: conversationDone, Ended: turn.End}
+	if !turn.End {
+		done.Suggestions = intentHandlers.Suggestions(&message.State)
+	}
+	return turn.End, finish(done)
+}
+
+// write sends the outputs to the client until outbox is closed, then reports the first error
+// Once a write fails the rest are dropped, as the connection is done for
+func (c *conversation) write(outbox <-chan conversationOutput, written chan<- error) {
+	var err error
+	for output := range outbox {
+		if err != nil {
+			continue
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(conversationWriteWait))
+		err = c.conn.WriteJSON(output)
+	}
+	written <- err
+}
+
+// newConversationPart makes a part of the SSML fragment, unless it's empty
+func newConversationPart(raw string) (conversationOutput, bool) {
+	if raw == "" {
+		return conversationOutput{}, false
+	}
+	return conversationOutput{
+		Type: conversationPart,
+		SSML: ssml.NewBuilder().Text(raw).String(),
+		Text: intentHandlers.PlainText(raw),
+	}, true
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// The user IDs in webhook bodies are only trusted, and progress and saves only kept,
+// when the request is shown to come from the platform
+// Alexa requests are verified by skillserver before reaching the handler
+
+// GoogleProjectID is the Actions project Google signs its webhook requests for
+// Without it requests from Google can't be verified, and their users stay anonymous
+var GoogleProjectID = ""
+
+// DialogflowAuth is the "username:password" the Dialogflow agent sends as basic auth
+// Without it requests from Dialogflow can't be verified, and their users stay anonymous
+var DialogflowAuth = ""
+
+// googleCertsURL serves the certificates Google signs its tokens with, by key ID
+const googleCertsURL = "https://www.googleapis.com/oauth2/v1/certs"
+
+// googleIssuer is the issuer of the tokens Google signs webhook requests with
+const googleIssuer = "https://accounts.google.com"
+
+// googleCertsTTL is how long the certificates are kept before fetching them again
+const googleCertsTTL = time.Hour
+
+// googleCerts caches the public keys of googleCertsURL
+var googleCerts = struct {
+	sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}{}
+
+// verifiedGoogleRequest reports whether the token Google sent with the request is valid for GoogleProjectID
+// AoG sends it in the Authorization header, conversational actions in Google-Assistant-Signature
+func verifiedGoogleRequest(token string) bool {
+	if GoogleProjectID == "" || token == "" {
+		return false
+	}
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return googleKey(kid)
+	})
+	if err != nil || !parsed.Valid {
+		log.Printf("routes: unverified Google request: %v", err)
+		return false
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	return claims.VerifyAudience(GoogleProjectID, true) && claims.VerifyIssuer(googleIssuer, true)
+}
+
+// googleKey returns the public key Google signed with, fetching the certificates when needed
+func googleKey(kid string) (*rsa.PublicKey, error) {
+	googleCerts.Lock()
+	defer googleCerts.Unlock()
+	if key, ok := googleCerts.keys[kid]; ok && time.Since(googleCerts.fetchedAt) < googleCertsTTL {
+		return key, nil
+	}
+	resp, err := http.Get(googleCertsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v fetching Google certificates", resp.StatusCode)
+	}
+	certs := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for id, cert := range certs {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(cert))
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	googleCerts.keys = keys
+	googleCerts.fetchedAt = time.Now()
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown Google key %q", kid)
+	}
+	return key, nil
+}
+
+// verifiedDialogflowRequest reports whether the request carries the basic auth of DialogflowAuth
+func verifiedDialogflowRequest(r *http.Request) bool {
+	if DialogflowAuth == "" {
+		return false
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(username+":"+password), []byte(DialogflowAuth)) == 1
+}
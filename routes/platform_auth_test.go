@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestVerifiedGoogleRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	googleCerts.keys = map[string]*rsa.PublicKey{"google": &key.PublicKey}
+	googleCerts.fetchedAt = time.Now()
+	GoogleProjectID = "project"
+	defer func() { GoogleProjectID = "" }()
+
+	sign := func(signer *rsa.PrivateKey, aud, iss string, exp time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"aud": aud, "iss": iss, "exp": exp.Unix()})
+		token.Header["kid"] = "google"
+		signed, err := token.SignedString(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid", sign(key, "project", googleIssuer, later), true},
+		{"missing", "", false},
+		{"other project", sign(key, "other", googleIssuer, later), false},
+		{"other issuer", sign(key, "project", "https://example.com", later), false},
+		{"expired", sign(key, "project", googleIssuer, time.Now().Add(-time.Hour)), false},
+		{"forged", sign(other, "project", googleIssuer, later), false},
+	}
+	for _, test := range tests {
+		if got := verifiedGoogleRequest(test.token); got != test.want {
+			t.Errorf("%v: verifiedGoogleRequest() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestVerifiedDialogflowRequest(t *testing.T) {
+	DialogflowAuth = "agent:secret"
+	defer func() { DialogflowAuth = "" }()
+
+	tests := []struct {
+		name               string
+		username, password string
+		want               bool
+	}{
+		{"valid", "agent", "secret", true},
+		{"wrong password", "agent", "guess", false},
+		{"missing", "", "", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/ai/v1/dialogflow", nil)
+		if test.username != "" {
+			r.SetBasicAuth(test.username, test.password)
+		}
+		if got := verifiedDialogflowRequest(r); got != test.want {
+			t.Errorf("%v: verifiedDialogflowRequest() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/prehandle"
+	"github.com/talkative-ai/core/router"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// PostActions router.Route
+// Path: "/ai/v1/actions",
+// Method: "POST",
+// Accepts a conversational actions (Actions Builder) webhook request
+// Responds with a conversational actions webhook response
+var PostActions = &router.Route{
+	Path:       "/ai/v1/actions",
+	Method:     "POST",
+	Handler:    http.HandlerFunc(postActionsHandler),
+	Prehandler: []prehandle.Prehandler{prehandle.SetJSON},
+}
+
+// actionsResponseBudget is how long a turn may take before the Assistant gives up on the webhook
+// It leaves headroom below the platform's 10 second limit
+const actionsResponseBudget = 8 * time.Second
+
+// actionsStateTTL is how long the state token held in the session params stays valid
+const actionsStateTTL = 20 * time.Minute
+
+const (
+	// actionsIntentMain is the intent conversational actions start with
+	actionsIntentMain = "actions.intent.MAIN"
+	// actionsIntentNoInput prefixes the intents sent when the user stays silent,
+	// e.g. "actions.intent.NO_INPUT_1"
+	actionsIntentNoInput = "actions.intent.NO_INPUT"
+	// actionsSceneEnd is the scene transitioned to in order to end the conversation
+	actionsSceneEnd = "actions.scene.END_CONVERSATION"
+)
+
+const (
+	// actionsStateParam is the session param holding the state token from one turn to the next
+	actionsStateParam = "brahmanState"
+	// actionsSignatureHeader carries the token Google signs the request with
+	actionsSignatureHeader = "Google-Assistant-Signature"
+	// actionsUserIDParam is the user param holding the ID Brahman gives verified users,
+	// as conversational actions don't provide one
+	actionsUserIDParam = "brahmanUserId"
+)
+
+type actionsRequest struct {
+	Handler struct {
+		Name string `json:"name"`
+	} `json:"handler"`
+	Intent struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"intent"`
+	Scene   *actionsScene  `json:"scene,omitempty"`
+	Session actionsSession `json:"session"`
+	User    struct {
+		Locale             string                     `json:"locale"`
+		VerificationStatus string                     `json:"verificationStatus"`
+		Params             map[string]json.RawMessage `json:"params"`
+	} `json:"user"`
+}
+
+type actionsScene struct {
+	Name string `json:"name,omitempty"`
+	Next *struct {
+		Name string `json:"name"`
+	} `json:"next,omitempty"`
+}
+
+type actionsSession struct {
+	ID           string                     `json:"id"`
+	Params       map[string]json.RawMessage `json:"params"`
+	LanguageCode string                     `json:"languageCode,omitempty"`
+}
+
+type actionsPrompt struct {
+	Override    bool `json:"override"`
+	FirstSimple struct {
+		Speech string `json:"speech"`
+		Text   string `json:"text,omitempty"`
+	} `json:"firstSimple"`
+}
+
+type actionsResponse struct {
+	Prompt  actionsPrompt  `json:"prompt"`
+	Scene   *actionsScene  `json:"scene,omitempty"`
+	Session actionsSession `json:"session"`
+	User    *struct {
+		Params map[string]interface{} `json:"params"`
+	} `json:"user,omitempty"`
+}
+
+// newActionsPrompt builds a prompt speaking the SSML, and displaying the text
+func newActionsPrompt(ssml, text string) actionsPrompt {
+	prompt := actionsPrompt{}
+	prompt.FirstSimple.Speech = ssml
+	prompt.FirstSimple.Text = text
+	return prompt
+}
+
+// endConversation makes the response end the conversation once the prompt is spoken
+func (res *actionsResponse) endConversation(scene *actionsScene) {
+	res.Scene = &actionsScene{Next: &struct {
+		Name string `json:"name"`
+	}{Name: actionsSceneEnd}}
+	if scene != nil {
+		res.Scene.Name = scene.Name
+	}
+}
+
+// actionsUserID returns the ID Brahman gave the user, giving a new one if needed
+// Only verified users can keep params from one conversation to the next, so others remain anonymous
+func (req *actionsRequest) actionsUserID() (userID string, isNew bool) {
+	if req.User.VerificationStatus != "VERIFIED" {
+		return "", false
+	}
+	if raw, ok := req.User.Params[actionsUserIDParam]; ok && json.Unmarshal(raw, &userID) == nil && userID != "" {
+		return userID, false
+	}
+	return uuid.NewV4().String(), true
+}
+
+// state reads the state from the token held in the session params
+// The token is signed like AoG's conversation token, so the state can't be forged by posting to the webhook
+func (req *actionsRequest) state() (models.MutableAIRequestState, error) {
+	raw, ok := req.Session.Params[actionsStateParam]
+	if !ok {
+		return models.MutableAIRequestState{}, nil
+	}
+	var token string
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return models.MutableAIRequestState{}, intentHandlers.StateCorrupt(err)
+	}
+	return parseConversationToken(token)
+}
+
+// postActionsHandler fulfills conversational actions webhook requests with the same pipeline as AoG
+// The state token is kept in the session params rather than a conversation token
+func postActionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("content-type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), actionsResponseBudget)
+	defer cancel()
+
+	request := &actionsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		respondActionsError(ctx, w, err, actionsSession{})
+		return
+	}
+	userLocale := request.User.Locale
+	if userLocale == "" {
+		userLocale = request.Session.LanguageCode
+	}
+	ctx = locale.With(ctx, userLocale)
+	userID, isNewUser := "", false
+	if verifiedGoogleRequest(r.Header.Get(actionsSignatureHeader)) {
+		userID, isNewUser = request.actionsUserID()
+		ctx = intentHandlers.WithUserID(ctx, userID)
+	}
+
+	message := &models.AIRequest{
+		State:      models.MutableAIRequestState{},
+		OutputSSML: ssml.NewBuilder(),
+	}
+	var err error
+	message.State, err = request.state()
+	if err != nil {
+		respondActionsError(ctx, w, err, request.Session)
+		return
+	}
+
+	turn := &intentHandlers.Turn{
+		Input:   request.Intent.Query,
+		New:     request.Intent.Name == actionsIntentMain,
+		NoInput: strings.HasPrefix(request.Intent.Name, actionsIntentNoInput),
+		Request: message,
+	}
+	if err := turn.Run(ctx); err != nil {
+		respondActionsError(ctx, w, err, request.Session)
+		return
+	}
+
+	response := &actionsResponse{
+		Session: actionsSession{ID: request.Session.ID},
+	}
+	if turn.Repeat {
+		if err := json.Unmarshal([]byte(message.State.PreviousResponse), &response.Prompt); err != nil {
+			respondActionsError(ctx, w, intentHandlers.StateCorrupt(err), request.Session)
+			return
+		}
+	} else {
+		response.Prompt = newActionsPrompt(message.OutputSSML.String(), message.OutputSSML.Raw())
+	}
+	if turn.End {
+		response.endConversation(request.Scene)
+	}
+
+	previousResponseBytes, err := json.Marshal(response.Prompt)
+	if err != nil {
+		respondActionsError(ctx, w, err, request.Session)
+		return
+	}
+	turn.Finish(ctx, string(previousResponseBytes))
+
+	stateToken, err := newStateToken(message.State, actionsStateTTL)
+	if err != nil {
+		respondActionsError(ctx, w, err, request.Session)
+		return
+	}
+	stateBytes, err := json.Marshal(stateToken)
+	if err != nil {
+		respondActionsError(ctx, w, err, request.Session)
+		return
+	}
+	response.Session.Params = map[string]json.RawMessage{actionsStateParam: stateBytes}
+	if isNewUser {
+		response.User = &struct {
+			Params map[string]interface{} `json:"params"`
+		}{Params: map[string]interface{}{actionsUserIDParam: userID}}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,18 +1,15 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
 	"time"
 
+	goredis "github.com/go-redis/redis"
 	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
 	ssml "github.com/talkative-ai/go-ssml"
-	snips "github.com/talkative-ai/snips-nlu-types"
 
 	"github.com/gorilla/mux"
 	"github.com/talkative-ai/go-alexa/skillserver"
@@ -34,10 +31,19 @@ var PostAlexa = &router.Route{
 	Method: "POST",
 }
 
+// alexaResponseBudget is how long a turn may take before Alexa gives up on the response
+// It leaves headroom below the platform's 8 second limit
+const alexaResponseBudget = 7 * time.Second
+
 func PostAlexaHandler(w http.ResponseWriter, r *http.Request) {
 
+	ctx, cancel := context.WithTimeout(r.Context(), alexaResponseBudget)
+	defer cancel()
+
 	urlparams := mux.Vars(r)
 	echoReq := r.Context().Value("echoRequest").(*skillserver.EchoRequest)
+	ctx = locale.With(ctx, echoReq.Request.Locale)
+	ctx = intentHandlers.WithUserID(ctx, echoReq.Session.User.UserID)
 	aiRequest := models.AIRequest{
 		State:      models.MutableAIRequestState{},
 		OutputSSML: ssml.NewBuilder(),
@@ -52,143 +58,77 @@ func PostAlexaHandler(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	aiRequest.State.ProjectID = projectID
-	aiRequest.State.PubID = projectID.String()
-	isRepeat := false
-	isExit := false
-
-	if echoReq.Session.New {
-		var setup models.RAResetApp
-		setup.Execute(&aiRequest)
-	} else {
+	turn := &intentHandlers.Turn{
+		New:       echoReq.Session.New,
+		ProjectID: projectID,
+		StopEnds:  true,
+		Request:   &aiRequest,
+	}
+
+	if echoReq.GetRequestType() == "SessionEndedRequest" {
+		// The session is over, e.g. after the reprompt went unanswered
+		// Alexa doesn't speak a response to this, it only expects an acknowledgement
+		json, _ := skillserver.NewEchoResponse().String()
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.Write(json)
+		return
+	}
+
+	if !turn.New {
 		stateString, err := redis.Instance.Get(models.KeynavContextConversation(echoReq.Session.SessionID)).Result()
-		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "bad_state",
-				Req:     r,
-				Log:     err.Error(),
-			})
+		if err == goredis.Nil {
+			respondAlexaError(ctx, w, intentHandlers.StateCorrupt(err))
+			return
+		} else if err != nil {
+			respondAlexaError(ctx, w, intentHandlers.DatabaseFailure(err))
+			return
+		}
+		if err := json.Unmarshal([]byte(stateString), &aiRequest.State); err != nil {
+			respondAlexaError(ctx, w, intentHandlers.StateCorrupt(err))
 			return
 		}
-		json.Unmarshal([]byte(stateString), &aiRequest.State)
-
-		parsedInput := snips.Result{}
 
-		var rawInput string
 		if rawSlot, ok := echoReq.Request.Intent.Slots["Raw"]; ok {
 			if rawSlot.Resolutions.ResolutionsPerAuthority[0].Values != nil {
-				rawInput = (*rawSlot.Resolutions.ResolutionsPerAuthority[0].Values)[0].Value.Name
+				turn.Input = (*rawSlot.Resolutions.ResolutionsPerAuthority[0].Values)[0].Value.Name
 			} else {
-				rawInput = rawSlot.Value
+				turn.Input = rawSlot.Value
 			}
 		} else {
-			parsedInput.Intent.Name = echoReq.Request.Intent.Name
-			parsedInput.Intent.Probability = 1
-		}
-
-		if parsedInput.Intent.Name == "" {
-			data := url.Values{}
-			data.Set("query", models.DialogInput(rawInput).Prepared())
-			// Note the context here is set to App, rather than Talkative
-			// because this isn't a conversation with Talkative,
-			// it's a conversation with the app
-			data.Set("context", models.KeynavStaticIntentsApp())
-
-			rq, err := http.NewRequest("POST", fmt.Sprintf("http://kalidasa:8080/v1/parse"), strings.NewReader(data.Encode()))
-			if err != nil {
-				fmt.Println("Error in TrainData", err)
-				// TODO: Handle errors
-			}
-			r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-			r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
-
-			client := http.Client{}
-			resp, err := client.Do(rq)
-
-			rawResponse, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				fmt.Println("Error in TrainData", err)
-				return
-				// TODO: Handle errors
-			}
-			json.Unmarshal(rawResponse, &parsedInput)
-		}
-
-		intentHandled := false
-		if parsedInput.Intent.Name == "repeat" {
-			intentHandled = true
-			isRepeat = true
-		} else if parsedInput.Intent.Name == "app.stop" {
-			intentHandled = true
-			isExit = true
-		} else if handler, ok := intentHandlers.List[parsedInput.Intent.Name]; ok {
-			err = handler(&parsedInput, &aiRequest)
-			if err == nil {
-				intentHandled = true
-			}
-			if err != nil && err != intentHandlers.ErrIntentNoMatch {
-				myerrors.Respond(w, &myerrors.MySimpleError{
-					Code:    http.StatusBadRequest,
-					Message: "unknown_error",
-					Req:     r,
-					Log:     err.Error(),
-				})
-				return
-			}
+			turn.Intent = echoReq.Request.Intent.Name
 		}
+	}
 
-		if !intentHandled {
-			err = intentHandlers.InAppHandler(rawInput, &aiRequest)
-			if err == intentHandlers.ErrIntentNoMatch {
-				intentHandlers.Unknown(nil, &aiRequest)
-			} else if err != nil {
-				myerrors.Respond(w, &myerrors.MySimpleError{
-					Code:    http.StatusBadRequest,
-					Message: "unknown_error",
-					Req:     r,
-					Log:     err.Error(),
-				})
-				return
-			}
-		}
+	if err := turn.Run(ctx); err != nil {
+		respondAlexaError(ctx, w, err)
+		return
 	}
 
 	echoResp := skillserver.NewEchoResponse()
-	if isRepeat {
+	if turn.Repeat {
 		json.Unmarshal([]byte(aiRequest.State.PreviousResponse), echoResp)
 	} else {
 		echoResp = echoResp.OutputSpeechSSML(aiRequest.OutputSSML.String())
-		previousResponseByte, err := json.Marshal(echoResp)
-		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "unknown_error",
-				Req:     r,
-				Log:     err.Error(),
-			})
-			return
+		// Alexa speaks the reprompt when the user stays silent, then ends the session
+		if reprompt := intentHandlers.NoInputReprompt(ctx, &aiRequest.State); !turn.End && reprompt != "" {
+			echoResp = echoResp.RepromptSSML(ssml.NewBuilder().Text(reprompt).String())
 		}
-		aiRequest.State.PreviousResponse = string(previousResponseByte)
 	}
+	previousResponseByte, err := json.Marshal(echoResp)
+	if err != nil {
+		respondAlexaError(ctx, w, err)
+		return
+	}
+	turn.Finish(ctx, string(previousResponseByte))
 
 	stateBytes, err := json.Marshal(aiRequest.State)
 	if err != nil {
-		myerrors.Respond(w, &myerrors.MySimpleError{
-			Code:    http.StatusBadRequest,
-			Message: "bad_state_marshal",
-			Req:     r,
-			Log:     err.Error(),
-		})
+		respondAlexaError(ctx, w, err)
 		return
 	}
 	redis.Instance.Set(models.KeynavContextConversation(echoReq.Session.SessionID), stateBytes, time.Hour*720)
 
-	if !isExit {
-		echoResp = echoResp.EndSession(false)
-	} else {
-		echoResp = echoResp.EndSession(true)
-	}
+	echoResp = echoResp.EndSession(turn.End)
 
 	json, _ := echoResp.String()
 	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
@@ -0,0 +1,264 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/myerrors"
+	"github.com/talkative-ai/core/prehandle"
+	"github.com/talkative-ai/core/redis"
+	"github.com/talkative-ai/core/router"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// PostChatSession router.Route
+// Path: "/ai/v1/chat/sessions",
+// Method: "POST",
+// Accepts postChatSessionInput
+// Responds with postChatOutput, starting the app
+var PostChatSession = &router.Route{
+	Path:       "/ai/v1/chat/sessions",
+	Method:     "POST",
+	Handler:    http.HandlerFunc(postChatSessionHandler),
+	Prehandler: []prehandle.Prehandler{prehandle.SetJSON, prehandle.RequireBody(65535)},
+}
+
+// PostChatMessage router.Route
+// Path: "/ai/v1/chat/sessions/{id}/messages",
+// Method: "POST",
+// Accepts postChatMessageInput
+// Responds with postChatOutput
+var PostChatMessage = &router.Route{
+	Path:       "/ai/v1/chat/sessions/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/messages",
+	Method:     "POST",
+	Handler:    http.HandlerFunc(postChatMessageHandler),
+	Prehandler: []prehandle.Prehandler{prehandle.SetJSON, prehandle.RequireBody(65535)},
+}
+
+// ChatSessionTTL is how long a chat session is kept after its last message
+var ChatSessionTTL = time.Minute * 30
+
+type postChatSessionInput struct {
+	ProjectID uuid.UUID
+	// Locale of the user, e.g. "es-ES". Defaults to English
+	Locale string
+	// UserToken identifies the user, if the client has signed them in
+	// It's a JWT signed with the Talkative JWT key by the client's backend, with the user's ID as its subject
+	// It lets the user resume their progress and use saves from one session to the next,
+	// which anonymous users can't
+	UserToken string
+}
+
+type postChatMessageInput struct {
+	Message string
+}
+
+type postChatOutput struct {
+	Session uuid.UUID
+	SSML    string
+	Text    string
+	// Suggestions are phrases the user could reply with
+	Suggestions []string
+	// Ended is set when the app ended the conversation, after which the session is gone
+	Ended bool
+}
+
+// chatSession is what's kept server-side between the messages of a chat session
+type chatSession struct {
+	State  models.MutableAIRequestState
+	Locale string
+	UserID string
+}
+
+// keynavChatSession is where a chat session is kept
+func keynavChatSession(sessionID uuid.UUID) string {
+	return fmt.Sprintf("chat:%v:session", sessionID.String())
+}
+
+// chatUserID reads the user ID from the user token, or returns an empty ID for anonymous users
+// User IDs are namespaced, so they can't be mistaken for those of another platform
+func chatUserID(userToken string) (string, error) {
+	if userToken == "" {
+		return "", nil
+	}
+	claims, err := utilities.ParseJTWClaims(userToken)
+	if err != nil {
+		return "", err
+	}
+	userID, ok := claims["sub"].(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("user token has no subject")
+	}
+	return fmt.Sprintf("chat:%v", userID), nil
+}
+
+// postChatSessionHandler starts a published app in a new chat session
+func postChatSessionHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input postChatSessionInput
+	if err := json.Unmarshal([]byte(r.Header.Get("X-Body")), &input); err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_body",
+			Req:     r,
+			Log:     err.Error(),
+		})
+		return
+	}
+
+	published, err := intentHandlers.IsPublished(input.ProjectID)
+	if err != nil {
+		respondAPIError(w, r, intentHandlers.KindOf(err), err.Error())
+		return
+	}
+	if !published {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusNotFound,
+			Message: "project_not_published",
+			Req:     r,
+		})
+		return
+	}
+
+	userID, err := chatUserID(input.UserToken)
+	if err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusUnauthorized,
+			Message: "bad_user_token",
+			Req:     r,
+			Log:     err.Error(),
+		})
+		return
+	}
+
+	session := &chatSession{
+		Locale: input.Locale,
+		UserID: userID,
+	}
+	turn := &intentHandlers.Turn{
+		New:       true,
+		ProjectID: input.ProjectID,
+	}
+	runChatTurn(w, r, uuid.NewV4(), session, turn)
+}
+
+// postChatMessageHandler handles a message of the user within a chat session
+func postChatMessageHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input postChatMessageInput
+	if err := json.Unmarshal([]byte(r.Header.Get("X-Body")), &input); err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_body",
+			Req:     r,
+			Log:     err.Error(),
+		})
+		return
+	}
+
+	sessionID, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_id",
+			Req:     r,
+		})
+		return
+	}
+
+	raw, err := redis.Instance.Get(keynavChatSession(sessionID)).Bytes()
+	if err == goredis.Nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusNotFound,
+			Message: "session_not_found",
+			Req:     r,
+		})
+		return
+	} else if err != nil {
+		respondAPIError(w, r, intentHandlers.ErrorDatabase, err.Error())
+		return
+	}
+	session := &chatSession{}
+	if err := json.Unmarshal(raw, session); err != nil {
+		respondAPIError(w, r, intentHandlers.ErrorStateCorrupt, err.Error())
+		return
+	}
+
+	turn := &intentHandlers.Turn{
+		Input: input.Message,
+	}
+	runChatTurn(w, r, sessionID, session, turn)
+}
+
+// runChatTurn runs the turn within the chat session, then keeps the session for the next message
+// The session is forgotten once the conversation ends
+func runChatTurn(w http.ResponseWriter, r *http.Request, sessionID uuid.UUID, session *chatSession, turn *intentHandlers.Turn) {
+	ctx := locale.With(r.Context(), session.Locale)
+	ctx = intentHandlers.WithUserID(ctx, session.UserID)
+
+	message := &models.AIRequest{
+		State:      session.State,
+		OutputSSML: ssml.NewBuilder(),
+	}
+	// The app is all there is to a chat session, so stopping it ends the conversation
+	turn.StopEnds = true
+	turn.Request = message
+
+	if err := turn.Run(ctx); err != nil {
+		respondAPIError(w, r, intentHandlers.KindOf(err), err.Error())
+		return
+	}
+
+	output := postChatOutput{Session: sessionID}
+	if turn.Repeat {
+		if err := json.Unmarshal([]byte(message.State.PreviousResponse), &output); err != nil {
+			respondAPIError(w, r, intentHandlers.ErrorStateCorrupt, err.Error())
+			return
+		}
+		output.Session = sessionID
+	} else {
+		output.SSML = message.OutputSSML.String()
+		output.Text = intentHandlers.PlainText(message.OutputSSML.Raw())
+	}
+	output.Ended = turn.End
+	output.Suggestions = []string{}
+	if !turn.End {
+		output.Suggestions = intentHandlers.Suggestions(&message.State)
+	}
+
+	previousResponseBytes, err := json.Marshal(struct{ SSML, Text string }{output.SSML, output.Text})
+	if err != nil {
+		respondAPIError(w, r, intentHandlers.ErrorInternal, err.Error())
+		return
+	}
+	turn.Finish(ctx, string(previousResponseBytes))
+
+	if turn.End {
+		if err := redis.Instance.Del(keynavChatSession(sessionID)).Err(); err != nil {
+			// The session expires on its own regardless
+			fmt.Println("Error deleting chat session", err)
+		}
+	} else {
+		session.State = message.State
+		sessionBytes, err := json.Marshal(session)
+		if err != nil {
+			respondAPIError(w, r, intentHandlers.ErrorInternal, err.Error())
+			return
+		}
+		if err := redis.Instance.Set(keynavChatSession(sessionID), sessionBytes, ChatSessionTTL).Err(); err != nil {
+			respondAPIError(w, r, intentHandlers.ErrorDatabase, err.Error())
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(output)
+}
@@ -1,17 +1,13 @@
 package routes
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
-	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/talkative-ai/aog"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
 	"github.com/talkative-ai/core/models"
 	ssml "github.com/talkative-ai/go-ssml"
 
@@ -37,16 +33,20 @@ var PostDemo = &router.Route{
 type postDemoInput struct {
 	Message string
 	State   *string
+	// Locale of the user, e.g. "es-ES". Defaults to English
+	Locale string
+	// Trace asks for how the turn was executed, see intentHandlers.Trace
+	Trace bool
 }
 type postDemoOutput struct {
 	SSML  string
 	Text  string
 	State *string
+	Trace interface{} `json:",omitempty"`
 }
 
-// AIRequestHandler handles requests that expect language parsing and an AI response
-// Currently expects ApiAi requests
-// This is the core functionality of Brahman, which routes to appropriate IntentHandlers
+// postDemoHander lets authors play their app as last compiled, as text
+// The first request starts the app, then each request carries the State returned by the previous one
 func postDemoHander(w http.ResponseWriter, r *http.Request) {
 
 	var input postDemoInput
@@ -57,11 +57,18 @@ func postDemoHander(w http.ResponseWriter, r *http.Request) {
 		myerrors.ServerError(w, r, err)
 		return
 	}
+	ctx := locale.With(r.Context(), input.Locale)
 
-	if input.State == nil {
-
-		// TODO: This should not duplicate the existing intent handler code when starting an app
+	message := &models.AIRequest{
+		State:      models.MutableAIRequestState{},
+		OutputSSML: ssml.NewBuilder(),
+	}
+	turn := &intentHandlers.Turn{
+		Input:   input.Message,
+		Request: message,
+	}
 
+	if input.State == nil {
 		// Parse the project ID from the URL
 		urlparams := mux.Vars(r)
 		projectID, err := uuid.FromString(urlparams["id"])
@@ -73,126 +80,61 @@ func postDemoHander(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		var setup models.RAResetApp
-		message := models.AIRequest{
-			State:      models.MutableAIRequestState{},
-			OutputSSML: ssml.NewBuilder(),
-		}
-		message.State = models.MutableAIRequestState{}
-		message.State.Demo = true
-		message.State.SessionID = uuid.NewV4()
-		message.State.ProjectID = projectID
-		message.State.PubID = fmt.Sprintf("demo:%v", projectID.String())
-		setup.Execute(&message)
-
-		response := aog.NewResponse("", message.OutputSSML.String(), message.OutputSSML.Raw(), false)
-		responseBytes, err := json.Marshal(response.ExpectedInputs)
-		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "marshal error",
-				Req:     r,
-				Log:     err.Error(),
-			})
-			return
-		}
-		message.State.PreviousResponse = string(responseBytes)
-
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"exp":  time.Now().Add(time.Minute * 3).Unix(),
-			"data": message.State,
-		})
-		// Sign the token and stringify
-		tokenString, err := token.SignedString([]byte(os.Getenv("JWT_KEY")))
-		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "tokenString signing problem",
-				Req:     r,
-				Log:     err.Error(),
-			})
-			return
-		}
-		output.SSML = message.OutputSSML.String()
-		output.Text = message.OutputSSML.Raw()
-		output.State = &tokenString
-
+		turn.New = true
+		turn.ProjectID = projectID
+		turn.Demo = true
 	} else {
-
-		req := aog.Request{
-			Conversation: aog.Conversation{
-				ConversationID:    "Demo",
-				Type:              "ACTIVE",
-				ConversationToken: *input.State,
-			},
-			Inputs: []aog.Input{
-				aog.Input{
-					Intent: aog.ConstIntentText,
-					RawInputs: []aog.RawInput{
-						aog.RawInput{
-							InputType: aog.ConstInputTypeKeyboard,
-							Query:     input.Message,
-						},
-					},
-					Arguments: []aog.InputArgument{
-						aog.InputArgument{
-							Name:      aog.ConstInputArgumentText,
-							RawText:   input.Message,
-							TextValue: input.Message,
-						},
-					},
-				},
-			},
-		}
-
-		payload, err := json.Marshal(req)
+		message.State, err = parseConversationToken(*input.State)
 		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "marshal error",
-				Req:     r,
-				Log:     err.Error(),
-			})
+			respondAPIError(w, r, intentHandlers.KindOf(err), err.Error())
 			return
 		}
-		rq, err := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:8080/ai/v1/google"), bytes.NewReader(payload))
+	}
+
+	var trace *intentHandlers.Trace
+	// The opening turn is a demo before the app has even started
+	if input.Trace && (turn.Demo || message.State.Demo) {
+		trace = &intentHandlers.Trace{}
+		ctx = intentHandlers.WithTrace(ctx, trace)
+	}
 
-		client := http.Client{}
-		resp, err := client.Do(rq)
+	if err := turn.Run(ctx); err != nil {
+		respondAPIError(w, r, intentHandlers.KindOf(err), err.Error())
+		return
+	}
 
-		rawResponse, err := ioutil.ReadAll(resp.Body)
+	// The response is kept in the AoG format, so the state works with either route
+	var response *aog.Response
+	if turn.Repeat {
+		response = aog.NewResponse("", "", "", true)
+		err = json.Unmarshal([]byte(message.State.PreviousResponse), &response.ExpectedInputs)
 		if err != nil {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "request error",
-				Req:     r,
-				Log:     err.Error(),
-			})
+			respondAPIError(w, r, intentHandlers.ErrorStateCorrupt, err.Error())
 			return
 		}
+		output.SSML, output.Text = simpleResponse(response.ExpectedInputs)
+	} else {
+		response = aog.NewResponse("", message.OutputSSML.String(), message.OutputSSML.Raw(), !turn.End)
+		output.SSML = message.OutputSSML.String()
+		output.Text = message.OutputSSML.Raw()
+	}
 
-		response := aog.Response{}
-
-		json.Unmarshal(rawResponse, &response)
-
-		output.State = &response.ConversationToken
-		if len(response.ExpectedInputs) > 0 &&
-			len(response.ExpectedInputs[0].InputPrompt.RichInitialPrompt.Items) > 0 &&
-			response.ExpectedInputs[0].InputPrompt.RichInitialPrompt.Items[0].(map[string]interface{}) != nil {
-			output.SSML = response.ExpectedInputs[0].InputPrompt.RichInitialPrompt.Items[0].(map[string]interface{})["simpleResponse"].(map[string]interface{})["ssml"].(string)
-			output.Text = response.ExpectedInputs[0].InputPrompt.RichInitialPrompt.Items[0].(map[string]interface{})["simpleResponse"].(map[string]interface{})["displayText"].(string)
-		} else {
-			myerrors.Respond(w, &myerrors.MySimpleError{
-				Code:    http.StatusBadRequest,
-				Message: "insufficient brahman response",
-				Req:     r,
-				Log:     fmt.Sprintf("%+v", response),
-			})
-			return
-		}
+	responseBytes, err := json.Marshal(response.ExpectedInputs)
+	if err != nil {
+		respondAPIError(w, r, intentHandlers.ErrorInternal, err.Error())
+		return
+	}
+	turn.Finish(ctx, string(responseBytes))
 
+	tokenString, err := newConversationToken(message.State)
+	if err != nil {
+		respondAPIError(w, r, intentHandlers.ErrorInternal, err.Error())
+		return
+	}
+	output.State = &tokenString
+	if trace != nil {
+		output.Trace = trace
 	}
 
 	json.NewEncoder(w).Encode(output)
-
 }
@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/talkative-ai/aog"
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/brahman/locale"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/prehandle"
+	"github.com/talkative-ai/core/router"
+	ssml "github.com/talkative-ai/go-ssml"
+)
+
+// PostDialogflow router.Route
+// Path: "/ai/v1/dialogflow",
+// Method: "POST",
+// Accepts a Dialogflow v2 WebhookRequest
+// Responds with a Dialogflow v2 WebhookResponse
+var PostDialogflow = &router.Route{
+	Path:       "/ai/v1/dialogflow",
+	Method:     "POST",
+	Handler:    http.HandlerFunc(postDialogflowHandler),
+	Prehandler: []prehandle.Prehandler{prehandle.SetJSON},
+}
+
+// dialogflowResponseBudget is how long a turn may take before Dialogflow gives up on the webhook
+// It leaves headroom below the platform's 5 second limit
+const dialogflowResponseBudget = 4 * time.Second
+
+// dialogflowStateContext is the output context carrying the state from one turn to the next
+const dialogflowStateContext = "brahman_state"
+
+// dialogflowStateLifespan is how many turns the state context outlives the last webhook call
+const dialogflowStateLifespan = 50
+
+// dialogflowStateTTL is how long the state stays valid, matching Dialogflow's session lifetime
+const dialogflowStateTTL = time.Minute * 20
+
+type dialogflowRequest struct {
+	Session     string `json:"session"`
+	QueryResult struct {
+		QueryText      string              `json:"queryText"`
+		LanguageCode   string              `json:"languageCode"`
+		OutputContexts []dialogflowContext `json:"outputContexts"`
+	} `json:"queryResult"`
+	OriginalDetectIntentRequest struct {
+		Source string `json:"source"`
+		// Payload is the original AoG request when the agent is integrated with the Assistant
+		Payload aog.Request `json:"payload"`
+	} `json:"originalDetectIntentRequest"`
+}
+
+type dialogflowContext struct {
+	Name          string                 `json:"name"`
+	LifespanCount int                    `json:"lifespanCount"`
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type dialogflowResponse struct {
+	FulfillmentText     string              `json:"fulfillmentText"`
+	FulfillmentMessages []dialogflowMessage `json:"fulfillmentMessages"`
+	Payload             dialogflowPayload   `json:"payload"`
+	OutputContexts      []dialogflowContext `json:"outputContexts,omitempty"`
+}
+
+type dialogflowMessage struct {
+	Text struct {
+		Text []string `json:"text"`
+	} `json:"text"`
+}
+
+type dialogflowPayload struct {
+	Google struct {
+		ExpectUserResponse bool `json:"expectUserResponse"`
+		RichResponse       struct {
+			Items []dialogflowItem `json:"items"`
+		} `json:"richResponse"`
+	} `json:"google"`
+}
+
+type dialogflowItem struct {
+	SimpleResponse struct {
+		TextToSpeech string `json:"textToSpeech"`
+		DisplayText  string `json:"displayText,omitempty"`
+	} `json:"simpleResponse"`
+}
+
+// newDialogflowResponse builds a response speaking the SSML, and displaying the text
+func newDialogflowResponse(ssml, text string, expectUserResponse bool) *dialogflowResponse {
+	response := &dialogflowResponse{FulfillmentText: text}
+	message := dialogflowMessage{}
+	message.Text.Text = []string{text}
+	response.FulfillmentMessages = []dialogflowMessage{message}
+	item := dialogflowItem{}
+	item.SimpleResponse.TextToSpeech = ssml
+	item.SimpleResponse.DisplayText = text
+	response.Payload.Google.ExpectUserResponse = expectUserResponse
+	response.Payload.Google.RichResponse.Items = []dialogflowItem{item}
+	return response
+}
+
+// stateContextName is the full name of the state context within the session
+func (req *dialogflowRequest) stateContextName() string {
+	return req.Session + "/contexts/" + dialogflowStateContext
+}
+
+// stateToken returns the state token carried by the state context, if any
+func (req *dialogflowRequest) stateToken() string {
+	for _, outputContext := range req.QueryResult.OutputContexts {
+		if outputContext.Name == req.stateContextName() {
+			token, _ := outputContext.Parameters["token"].(string)
+			return token
+		}
+	}
+	return ""
+}
+
+// postDialogflowHandler fulfills Dialogflow v2 webhook requests with the same pipeline as AoG
+// The agent's intents only serve to call the webhook, the query is matched by Brahman
+func postDialogflowHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("content-type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), dialogflowResponseBudget)
+	defer cancel()
+
+	request := &dialogflowRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		respondDialogflowError(ctx, w, err, "", "")
+		return
+	}
+	payload := request.OriginalDetectIntentRequest.Payload
+	userLocale := payload.User.Locale
+	if userLocale == "" {
+		userLocale = request.QueryResult.LanguageCode
+	}
+	ctx = locale.With(ctx, userLocale)
+	if verifiedDialogflowRequest(r) {
+		ctx = intentHandlers.WithUserID(ctx, payload.User.UserID)
+	}
+
+	stateContext := request.stateContextName()
+	stateToken := request.stateToken()
+	message := &models.AIRequest{
+		State:      models.MutableAIRequestState{},
+		OutputSSML: ssml.NewBuilder(),
+	}
+	var err error
+	message.State, err = parseConversationToken(stateToken)
+	if err != nil {
+		respondDialogflowError(ctx, w, err, stateContext, stateToken)
+		return
+	}
+
+	turn := &intentHandlers.Turn{
+		Input: request.QueryResult.QueryText,
+		// Every response carries the state context, so a request without one starts the conversation
+		// whatever the agent named its welcome intent
+		New:     stateToken == "",
+		Request: message,
+	}
+	if len(payload.Inputs) > 0 {
+		turn.NoInput = payload.Inputs[0].Intent == aogIntentNoInput
+	}
+
+	if err := turn.Run(ctx); err != nil {
+		respondDialogflowError(ctx, w, err, stateContext, stateToken)
+		return
+	}
+
+	response := &dialogflowResponse{}
+	if turn.Repeat {
+		if err := json.Unmarshal([]byte(message.State.PreviousResponse), response); err != nil {
+			respondDialogflowError(ctx, w, intentHandlers.StateCorrupt(err), stateContext, stateToken)
+			return
+		}
+	} else {
+		response = newDialogflowResponse(message.OutputSSML.String(), message.OutputSSML.Raw(), !turn.End)
+	}
+
+	previousResponseBytes, err := json.Marshal(response)
+	if err != nil {
+		respondDialogflowError(ctx, w, err, stateContext, stateToken)
+		return
+	}
+	turn.Finish(ctx, string(previousResponseBytes))
+
+	stateToken, err = newStateToken(message.State, dialogflowStateTTL)
+	if err != nil {
+		respondDialogflowError(ctx, w, err, stateContext, "")
+		return
+	}
+	response.OutputContexts = []dialogflowContext{
+		dialogflowContext{
+			Name:          stateContext,
+			LifespanCount: dialogflowStateLifespan,
+			Parameters:    map[string]interface{}{"token": stateToken},
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
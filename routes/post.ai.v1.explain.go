@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/talkative-ai/brahman/intent_handlers"
+	"github.com/talkative-ai/core"
+	"github.com/talkative-ai/core/models"
+	"github.com/talkative-ai/core/myerrors"
+	"github.com/talkative-ai/core/prehandle"
+	"github.com/talkative-ai/core/router"
+	ssml "github.com/talkative-ai/go-ssml"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// PostExplain router.Route
+// Path: "/ai/v1/explain",
+// Method: "POST",
+// Accepts postExplainInput
+// Requires the workbench token of an author of the project
+// Responds with the intentHandlers.Explanation of how the message would be matched
+var PostExplain = &router.Route{
+	Path:       "/ai/v1/explain",
+	Method:     "POST",
+	Handler:    http.HandlerFunc(postExplainHandler),
+	Prehandler: []prehandle.Prehandler{prehandle.SetJSON, requireWorkbenchAuth, prehandle.RequireBody(65535)},
+}
+
+type postExplainInput struct {
+	// PubID is a published project ID, or "demo:" followed by a project ID,
+	// optionally suffixed with the language it was compiled in, e.g. "{projectID}@es"
+	PubID string
+	// State is either a state token as returned by the demo route, or the state as JSON
+	// When omitted, the app is matched from its starting state
+	State   json.RawMessage
+	Message string
+}
+
+// postExplainHandler explains which dialog an utterance would trigger, and why
+// It's a debugging aid for authors whose dialogs aren't triggering as expected
+func postExplainHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input postExplainInput
+	err := json.Unmarshal([]byte(r.Header.Get("X-Body")), &input)
+	if err != nil {
+		myerrors.ServerError(w, r, err)
+		return
+	}
+
+	projectID, demo, _, err := intentHandlers.ParsePubID(input.PubID)
+	if err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_id",
+			Req:     r,
+		})
+		return
+	}
+
+	// Explanations expose the dialogs of the project, so only its authors may ask for them
+	author, err := intentHandlers.IsAuthor(uuid.FromStringOrNil(r.Header.Get(workbenchUserHeader)), projectID)
+	if err != nil {
+		myerrors.ServerError(w, r, err)
+		return
+	}
+	if !author {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Req:     r,
+		})
+		return
+	}
+
+	message := models.AIRequest{
+		State:      models.MutableAIRequestState{},
+		OutputSSML: ssml.NewBuilder(),
+	}
+	if len(input.State) == 0 {
+		message.State.ProjectID = projectID
+		message.State.PubID = input.PubID
+		message.State.Demo = demo
+		var setup models.RAResetApp
+		setup.Execute(&message)
+	} else if err := parseSerializedState(input.State, &message.State); err != nil {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "bad_state",
+			Req:     r,
+			Log:     err.Error(),
+		})
+		return
+	}
+
+	if message.State.ProjectID != projectID {
+		myerrors.Respond(w, &myerrors.MySimpleError{
+			Code:    http.StatusBadRequest,
+			Message: "state_project_mismatch",
+			Req:     r,
+		})
+		return
+	}
+	message.State.PubID = input.PubID
+
+	explanation, err := intentHandlers.Explain(r.Context(), input.Message, &message.State)
+	if err != nil {
+		myerrors.ServerError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(explanation)
+}
+
+// parseSerializedState reads a state given either as a state token or as JSON
+func parseSerializedState(raw json.RawMessage, state *models.MutableAIRequestState) error {
+	var token string
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return json.Unmarshal(raw, state)
+	}
+
+	claims, err := utilities.ParseJTWClaims(token)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(claims["data"])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, state)
+}
@@ -0,0 +1,92 @@
+package saves
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/talkative-ai/core/db"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// Postgres stores saves in the user_saves table,
+// created by migrations/001_user_saves.up.sql
+type Postgres struct{}
+
+func (s *Postgres) Put(userID string, projectID uuid.UUID, save *Save) error {
+	raw, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	_, err = db.Instance.Exec(`
+		INSERT INTO user_saves ("UserID", "ProjectID", "Name", "Save", "SavedAt")
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT ("UserID", "ProjectID", "Name")
+		DO UPDATE SET "Save"=EXCLUDED."Save", "SavedAt"=EXCLUDED."SavedAt"
+	`, userID, projectID, save.Name, raw, save.SavedAt)
+	return err
+}
+
+func (s *Postgres) Get(userID string, projectID uuid.UUID, name string) (*Save, error) {
+	var raw []byte
+	err := db.Instance.QueryRow(`
+		SELECT "Save"
+		FROM user_saves
+		WHERE "UserID"=$1 AND "ProjectID"=$2 AND "Name"=$3
+	`, userID, projectID, name).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	save := &Save{}
+	if err := json.Unmarshal(raw, save); err != nil {
+		return nil, err
+	}
+	return save, nil
+}
+
+func (s *Postgres) List(userID string, projectID uuid.UUID) ([]Save, error) {
+	rows, err := db.Instance.Query(`
+		SELECT "Save"
+		FROM user_saves
+		WHERE "UserID"=$1 AND "ProjectID"=$2
+		ORDER BY "SavedAt" DESC
+	`, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Save{}
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		save := Save{}
+		if err := json.Unmarshal(raw, &save); err != nil {
+			return nil, err
+		}
+		list = append(list, save)
+	}
+	return list, rows.Err()
+}
+
+func (s *Postgres) Delete(userID string, projectID uuid.UUID, name string) error {
+	result, err := db.Instance.Exec(`
+		DELETE FROM user_saves
+		WHERE "UserID"=$1 AND "ProjectID"=$2 AND "Name"=$3
+	`, userID, projectID, name)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package saves
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/talkative-ai/core/redis"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// Redis stores the saves of a user in a project as a hash of names to saves
+type Redis struct {
+	// TTL is how long the saves are kept after the last one was written
+	// Zero keeps them forever
+	TTL time.Duration
+}
+
+// KeynavUserSaves is where the saves of a user within a project live
+func KeynavUserSaves(userID string, projectID uuid.UUID) string {
+	return fmt.Sprintf("user:%v:project:%v:saves", userID, projectID.String())
+}
+
+func (s *Redis) Put(userID string, projectID uuid.UUID, save *Save) error {
+	raw, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	key := KeynavUserSaves(userID, projectID)
+	if err := redis.Instance.HSet(key, save.Name, raw).Err(); err != nil {
+		return err
+	}
+	if s.TTL > 0 {
+		return redis.Instance.Expire(key, s.TTL).Err()
+	}
+	return nil
+}
+
+func (s *Redis) Get(userID string, projectID uuid.UUID, name string) (*Save, error) {
+	raw, err := redis.Instance.HGet(KeynavUserSaves(userID, projectID), name).Bytes()
+	if err == goredis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	save := &Save{}
+	if err := json.Unmarshal(raw, save); err != nil {
+		return nil, err
+	}
+	return save, nil
+}
+
+func (s *Redis) List(userID string, projectID uuid.UUID) ([]Save, error) {
+	all, err := redis.Instance.HGetAll(KeynavUserSaves(userID, projectID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := []Save{}
+	for _, raw := range all {
+		save := Save{}
+		if err := json.Unmarshal([]byte(raw), &save); err != nil {
+			return nil, err
+		}
+		list = append(list, save)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SavedAt.After(list[j].SavedAt)
+	})
+	return list, nil
+}
+
+func (s *Redis) Delete(userID string, projectID uuid.UUID, name string) error {
+	deleted, err := redis.Instance.HDel(KeynavUserSaves(userID, projectID), name).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
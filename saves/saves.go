@@ -0,0 +1,42 @@
+package saves
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/talkative-ai/core/models"
+	uuid "github.com/talkative-ai/go.uuid"
+)
+
+// Save is a named snapshot of a player's progress in an app
+type Save struct {
+	Name    string
+	State   models.MutableAIRequestState
+	SavedAt time.Time
+}
+
+// ErrNotFound occurs when there is no save with the given name
+var ErrNotFound = fmt.Errorf("saves:not_found")
+
+// Store keeps the named saves of each user, per project
+// Names are expected to be normalized with NormalizeName
+type Store interface {
+	// Put creates the save, or replaces the one of the same name
+	Put(userID string, projectID uuid.UUID, save *Save) error
+	// Get returns ErrNotFound if there's no such save
+	Get(userID string, projectID uuid.UUID, name string) (*Save, error)
+	// List returns the saves of the user in the project, most recent first
+	List(userID string, projectID uuid.UUID) ([]Save, error)
+	// Delete returns ErrNotFound if there's no such save
+	Delete(userID string, projectID uuid.UUID, name string) error
+}
+
+// Instance is the Store used throughout Brahman
+var Instance Store = &Redis{}
+
+// NormalizeName makes spoken variations of a save name equal,
+// e.g. "Castle  Run" and "castle run"
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}